@@ -0,0 +1,135 @@
+package accesslog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testAccessLog = "time:02/Jan/2024:03:04:05 +0900\thost:127.0.0.1\treq:GET /users/1?page=2 HTTP/1.1\tstatus:200\tmethod:GET\turi:/users/1?page=2\tsize:100\treqtime:0.100\n" +
+	"time:02/Jan/2024:03:04:06 +0900\thost:127.0.0.1\treq:GET /users/2 HTTP/1.1\tstatus:200\tmethod:GET\turi:/users/2\tsize:300\treqtime:0.300\n" +
+	"time:02/Jan/2024:03:04:07 +0900\thost:127.0.0.1\treq:GET /users/new HTTP/1.1\tstatus:404\tmethod:GET\turi:/users/new\tsize:0\treqtime:0.010\n" +
+	"time:02/Jan/2024:03:04:08 +0900\thost:127.0.0.1\treq:POST /users HTTP/1.1\tstatus:500\tsize:10\treqtime:1.000\n" +
+	"time:02/Jan/2024:03:04:09 +0900\thost:127.0.0.1\treq:GET /static/js/app.js HTTP/1.1\tstatus:304\tmethod:GET\turi:/static/js/app.js\tsize:0\treqtime:0.001\n" +
+	"broken line\n"
+
+func TestRouteMatcher(t *testing.T) {
+	m := NewRouteMatcher([]string{"/users/:id", "/users/new", "/users/:id/posts", "/static/*", "/users"})
+	testcases := []struct {
+		uri    string
+		expect string
+	}{
+		{uri: "/users/1", expect: "/users/:id"},
+		{uri: "/users/1?page=2", expect: "/users/:id"},
+		{uri: "/users/new", expect: "/users/new"},
+		{uri: "/users/1/posts", expect: "/users/:id/posts"},
+		{uri: "/users", expect: "/users"},
+		{uri: "/static/js/app.js", expect: "/static/*"},
+		{uri: "/unknown/1?q=1", expect: "/unknown/1"},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.uri, func(t *testing.T) {
+			actual := m.Match(tt.uri)
+			if actual != tt.expect {
+				t.Fatalf("expect: '%s' but actual: '%s'", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	stats, err := Analyze(strings.NewReader(testAccessLog), []string{"/users/:id", "/users/new", "/users", "/static/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("unexpected stats: %d", len(stats))
+	}
+	// sorted by the sum of reqtime in descending order.
+	if stats[0].Method != "POST" || stats[0].Route != "/users" || stats[0].Status[5] != 1 {
+		t.Fatalf("unexpected stat: %+v", stats[0])
+	}
+	users := stats[1]
+	if users.Route != "/users/:id" || users.Count != 2 || users.Status[2] != 2 {
+		t.Fatalf("unexpected stat: %+v", users)
+	}
+	if users.ReqTime.Min != 0.1 || users.ReqTime.Max != 0.3 || users.ReqTime.Percentile(50) != 0.1 || users.ReqTime.Percentile(99) != 0.3 {
+		t.Fatalf("unexpected reqtime: %+v", users.ReqTime)
+	}
+	if users.Size.Sum != 400 || users.Size.Avg() != 200 {
+		t.Fatalf("unexpected size: %+v", users.Size)
+	}
+
+	stats.Sort(SortByCount, true)
+	if stats[0].Route != "/users/:id" {
+		t.Fatalf("unexpected order: %+v", stats[0])
+	}
+	stats.Sort(SortByURI, false)
+	if stats[0].Route != "/static/*" || stats[len(stats)-1].Route != "/users/new" {
+		t.Fatalf("unexpected order: %s %s", stats[0].Route, stats[len(stats)-1].Route)
+	}
+	if _, err := ParseSortKey("P99"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseSortKey("unknown"); err == nil {
+		t.Fatal("expected error for unknown sort key")
+	}
+
+	var b bytes.Buffer
+	if err := stats.WriteTable(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("unexpected table:\n%s", b.String())
+	}
+	if !strings.HasPrefix(lines[0], "| COUNT | 1XX |") || !strings.Contains(lines[0], "|   P99 |") {
+		t.Fatalf("unexpected header: %s", lines[0])
+	}
+	if !strings.Contains(b.String(), "| GET    | /users/:id ") {
+		t.Fatalf("unexpected table:\n%s", b.String())
+	}
+}
+
+func TestAnalyzeKataribe(t *testing.T) {
+	report, err := AnalyzeKataribe(strings.NewReader(testAccessLog), []string{"/users/:id", "/users/new", "/users"}, KataribeConfig{
+		SlowCount: 2,
+		Bundles:   []Bundle{{Name: "static files", Regexp: `^GET /static/`}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bundle *Stat
+	for _, stat := range report.Stats {
+		if stat.Route == "static files" {
+			bundle = stat
+		}
+	}
+	if bundle == nil || bundle.Method != "" || bundle.Count != 1 || bundle.Status[3] != 1 {
+		t.Fatalf("unexpected bundle: %+v", bundle)
+	}
+	if len(report.Slow) != 2 || report.Slow[0].ReqTime != 1 || report.Slow[1].ReqTime != 0.3 {
+		t.Fatalf("unexpected slow requests: %+v", report.Slow)
+	}
+
+	var b bytes.Buffer
+	if err := report.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Top 4 Sort By Total\n",
+		"Top 4 Sort By Mean\n",
+		"Top 4 Sort By Standard Deviation\n",
+		"TOP 2 Slow Requests\n  1  1.000  POST /users\n  2  0.300  GET /users/2\n",
+		"  GET /users/:id\n",
+		"  static files\n",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Fatalf("report doesn't contain %q:\n%s", expected, b.String())
+		}
+	}
+
+	if _, err := AnalyzeKataribe(strings.NewReader(testAccessLog), nil, KataribeConfig{Bundles: []Bundle{{Regexp: "("}}}); err == nil {
+		t.Fatal("expected error for invalid bundle")
+	}
+}
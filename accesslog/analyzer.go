@@ -0,0 +1,240 @@
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/echo-tools/metric"
+)
+
+// Stat is the stats of the requests of a method and a route, or of a bundle.
+type Stat struct {
+	// Method is empty for a bundle.
+	Method string
+	Route  string
+	Count  int
+	// Status is the count of each status class: Status[1] is 1xx and Status[5] is 5xx.
+	Status  [6]int
+	ReqTime *metric.Metric
+	Size    *metric.Metric
+}
+
+// Analyzer groups the requests by the echo route like `alp -m` .
+type Analyzer struct {
+	matcher *RouteMatcher
+	bundles []*Bundle
+	stats   map[string]*Stat
+}
+
+// NewAnalyzer creates an analyzer which groups the requests by routes.
+// The requests matching a bundle are grouped by the bundle prior to routes.
+func NewAnalyzer(routes []string, bundles ...*Bundle) *Analyzer {
+	return &Analyzer{matcher: NewRouteMatcher(routes), bundles: bundles, stats: map[string]*Stat{}}
+}
+
+func (a *Analyzer) Add(e *Entry) {
+	method, route := e.Method, ""
+	for _, bundle := range a.bundles {
+		if bundle.match(e) {
+			method, route = "", bundle.Name
+			break
+		}
+	}
+	if route == "" {
+		route = a.matcher.Match(e.URI)
+	}
+	key := method + " " + route
+	stat, exists := a.stats[key]
+	if !exists {
+		stat = &Stat{Method: method, Route: route, ReqTime: metric.New(), Size: metric.New()}
+		a.stats[key] = stat
+	}
+	stat.Count++
+	if class := e.Status / 100; class >= 1 && class <= 5 {
+		stat.Status[class]++
+	}
+	stat.ReqTime.Add(e.ReqTime)
+	stat.Size.Add(float64(e.Size))
+}
+
+// Stats returns the stats sorted by the total of reqtime.
+func (a *Analyzer) Stats() Stats {
+	stats := make(Stats, 0, len(a.stats))
+	for _, stat := range a.stats {
+		stats = append(stats, stat)
+	}
+	stats.Sort(SortBySum, true)
+	return stats
+}
+
+// Analyze reads the LTSV access log and groups the requests by routes.
+func Analyze(r io.Reader, routes []string) (Stats, error) {
+	a := NewAnalyzer(routes)
+	if err := Parse(r, func(e *Entry) error {
+		a.Add(e)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return a.Stats(), nil
+}
+
+type Stats []*Stat
+
+// SortKey is the column to sort the table by. The names are the same as `alp --sort` .
+type SortKey string
+
+const (
+	SortByCount   SortKey = "count"
+	SortByMethod  SortKey = "method"
+	SortByURI     SortKey = "uri"
+	SortByMin     SortKey = "min"
+	SortByMax     SortKey = "max"
+	SortBySum     SortKey = "sum"
+	SortByAvg     SortKey = "avg"
+	SortByP50     SortKey = "p50"
+	SortByP90     SortKey = "p90"
+	SortByP95     SortKey = "p95"
+	SortByP99     SortKey = "p99"
+	SortByMaxBody SortKey = "max-body"
+	SortBySumBody SortKey = "sum-body"
+	SortByAvgBody SortKey = "avg-body"
+)
+
+// ParseSortKey returns the sort key of name or an error for an unknown name.
+func ParseSortKey(name string) (SortKey, error) {
+	key := SortKey(strings.ToLower(name))
+	if _, exists := sortValues[key]; exists || key == SortByMethod || key == SortByURI {
+		return key, nil
+	}
+	return "", fmt.Errorf("unknown sort key: %s", name)
+}
+
+var sortValues = map[SortKey]func(*Stat) float64{
+	SortByCount:   func(s *Stat) float64 { return float64(s.Count) },
+	SortByMin:     func(s *Stat) float64 { return s.ReqTime.Min },
+	SortByMax:     func(s *Stat) float64 { return s.ReqTime.Max },
+	SortBySum:     func(s *Stat) float64 { return s.ReqTime.Sum },
+	SortByAvg:     func(s *Stat) float64 { return s.ReqTime.Avg() },
+	SortByP50:     func(s *Stat) float64 { return s.ReqTime.Percentile(50) },
+	SortByP90:     func(s *Stat) float64 { return s.ReqTime.Percentile(90) },
+	SortByP95:     func(s *Stat) float64 { return s.ReqTime.Percentile(95) },
+	SortByP99:     func(s *Stat) float64 { return s.ReqTime.Percentile(99) },
+	SortByMaxBody: func(s *Stat) float64 { return s.Size.Max },
+	SortBySumBody: func(s *Stat) float64 { return s.Size.Sum },
+	SortByAvgBody: func(s *Stat) float64 { return s.Size.Avg() },
+}
+
+// Sort sorts the stats by key in ascending order, or descending order if reverse is true.
+// The stats of the same value are sorted by the method and the route.
+func (s Stats) Sort(key SortKey, reverse bool) {
+	less := func(a, b *Stat) bool {
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Route < b.Route
+	}
+	sort.SliceStable(s, func(i, j int) bool {
+		a, b := s[i], s[j]
+		switch key {
+		case SortByMethod:
+			if a.Method != b.Method {
+				return a.Method < b.Method != reverse
+			}
+			return a.Route < b.Route
+		case SortByURI:
+			if a.Route != b.Route {
+				return a.Route < b.Route != reverse
+			}
+			return a.Method < b.Method
+		}
+		value, exists := sortValues[key]
+		if !exists {
+			value = sortValues[SortBySum]
+		}
+		if va, vb := value(a), value(b); va != vb {
+			return va < vb != reverse
+		}
+		return less(a, b)
+	})
+}
+
+// WriteTable writes the stats as a markdown table in the same columns as alp, which the trend page of the profiler reads.
+func (s Stats) WriteTable(w io.Writer) error {
+	header := []string{
+		"COUNT", "1XX", "2XX", "3XX", "4XX", "5XX", "METHOD", "URI",
+		"MIN", "MAX", "SUM", "AVG", "P50", "P90", "P95", "P99",
+		"MIN(BODY)", "MAX(BODY)", "SUM(BODY)", "AVG(BODY)",
+	}
+	rows := [][]string{header}
+	for _, stat := range s {
+		rows = append(rows, []string{
+			strconv.Itoa(stat.Count),
+			strconv.Itoa(stat.Status[1]),
+			strconv.Itoa(stat.Status[2]),
+			strconv.Itoa(stat.Status[3]),
+			strconv.Itoa(stat.Status[4]),
+			strconv.Itoa(stat.Status[5]),
+			stat.Method,
+			stat.Route,
+			formatFloat(stat.ReqTime.Min),
+			formatFloat(stat.ReqTime.Max),
+			formatFloat(stat.ReqTime.Sum),
+			formatFloat(stat.ReqTime.Avg()),
+			formatFloat(stat.ReqTime.Percentile(50)),
+			formatFloat(stat.ReqTime.Percentile(90)),
+			formatFloat(stat.ReqTime.Percentile(95)),
+			formatFloat(stat.ReqTime.Percentile(99)),
+			formatFloat(stat.Size.Min),
+			formatFloat(stat.Size.Max),
+			formatFloat(stat.Size.Sum),
+			formatFloat(stat.Size.Avg()),
+		})
+	}
+	widths := columnWidths(rows)
+	var b strings.Builder
+	for idx, row := range rows {
+		writeRow(&b, row, widths)
+		if idx == 0 {
+			separator := make([]string, len(widths))
+			for i, width := range widths {
+				separator[i] = strings.Repeat("-", width)
+			}
+			writeRow(&b, separator, widths)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeRow aligns the text columns ( METHOD and URI ) to the left and the others to the right.
+func writeRow(b *strings.Builder, row []string, widths []int) {
+	b.WriteString("|")
+	for idx, cell := range row {
+		if idx == 6 || idx == 7 {
+			fmt.Fprintf(b, " %-*s |", widths[idx], cell)
+		} else {
+			fmt.Fprintf(b, " %*s |", widths[idx], cell)
+		}
+	}
+	b.WriteString("\n")
+}
+
+func columnWidths(rows [][]string) []int {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for idx, cell := range row {
+			if len(cell) > widths[idx] {
+				widths[idx] = len(cell)
+			}
+		}
+	}
+	return widths
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
@@ -0,0 +1,174 @@
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bundle groups the requests matching Regexp into a row named Name like [[bundle]] of the config of kataribe.
+// Regexp is matched against "<method> <uri>" .
+type Bundle struct {
+	Name   string `json:"name"`
+	Regexp string `json:"regexp"`
+	re     *regexp.Regexp
+}
+
+func (b *Bundle) compile() error {
+	re, err := regexp.Compile(b.Regexp)
+	if err != nil {
+		return fmt.Errorf("failed to compile bundle %s: %w", b.Name, err)
+	}
+	b.re = re
+	if b.Name == "" {
+		b.Name = b.Regexp
+	}
+	return nil
+}
+
+func (b *Bundle) match(e *Entry) bool {
+	return b.re != nil && b.re.MatchString(e.Method+" "+e.URI)
+}
+
+const (
+	defaultRankingCount = 20
+	defaultSlowCount    = 37
+)
+
+// KataribeConfig is the config of the report. The zero value uses the same counts as the default config of kataribe.
+type KataribeConfig struct {
+	// RankingCount is the number of the rows of each ranking.
+	RankingCount int `json:"rankingCount"`
+	// SlowCount is the number of the slowest requests.
+	SlowCount int      `json:"slowCount"`
+	Bundles   []Bundle `json:"bundles"`
+}
+
+// KataribeReport is the report in the format of kataribe: the rankings of the routes by the total, the mean
+// and the standard deviation of reqtime, and the slowest requests.
+type KataribeReport struct {
+	Stats        Stats
+	Slow         []*Entry
+	rankingCount int
+}
+
+// AnalyzeKataribe reads the LTSV access log and makes the report grouped by bundles and routes.
+func AnalyzeKataribe(r io.Reader, routes []string, cfg KataribeConfig) (*KataribeReport, error) {
+	bundles := make([]*Bundle, 0, len(cfg.Bundles))
+	for idx := range cfg.Bundles {
+		bundle := cfg.Bundles[idx]
+		if err := bundle.compile(); err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, &bundle)
+	}
+	rankingCount := cfg.RankingCount
+	if rankingCount <= 0 {
+		rankingCount = defaultRankingCount
+	}
+	slowCount := cfg.SlowCount
+	if slowCount <= 0 {
+		slowCount = defaultSlowCount
+	}
+	a := NewAnalyzer(routes, bundles...)
+	var slow []*Entry
+	if err := Parse(r, func(e *Entry) error {
+		a.Add(e)
+		// keep the slowest requests only.
+		idx := sort.Search(len(slow), func(i int) bool { return slow[i].ReqTime < e.ReqTime })
+		if idx < slowCount {
+			slow = append(slow, nil)
+			copy(slow[idx+1:], slow[idx:])
+			slow[idx] = e
+			if len(slow) > slowCount {
+				slow = slow[:slowCount]
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &KataribeReport{Stats: a.Stats(), Slow: slow, rankingCount: rankingCount}, nil
+}
+
+// Write writes the report.
+func (r *KataribeReport) Write(w io.Writer) error {
+	var b strings.Builder
+	for _, ranking := range []struct {
+		title string
+		value func(*Stat) float64
+	}{
+		{title: "Sort By Total", value: func(s *Stat) float64 { return s.ReqTime.Sum }},
+		{title: "Sort By Mean", value: func(s *Stat) float64 { return s.ReqTime.Avg() }},
+		{title: "Sort By Standard Deviation", value: func(s *Stat) float64 { return s.ReqTime.Stddev() }},
+	} {
+		stats := make(Stats, len(r.Stats))
+		copy(stats, r.Stats)
+		sort.SliceStable(stats, func(i, j int) bool {
+			return ranking.value(stats[i]) > ranking.value(stats[j])
+		})
+		if len(stats) > r.rankingCount {
+			stats = stats[:r.rankingCount]
+		}
+		fmt.Fprintf(&b, "Top %d %s\n", len(stats), ranking.title)
+		writeKataribeStats(&b, stats)
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "TOP %d Slow Requests\n", len(r.Slow))
+	for idx, e := range r.Slow {
+		fmt.Fprintf(&b, "%3d  %s  %s %s\n", idx+1, formatFloat(e.ReqTime), e.Method, e.URI)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeKataribeStats(b *strings.Builder, stats Stats) {
+	header := []string{
+		"Count", "Total", "Mean", "Stddev", "Min", "P50.0", "P90.0", "P95.0", "P99.0", "Max",
+		"2xx", "3xx", "4xx", "5xx", "TotalBytes", "MinBytes", "MeanBytes", "MaxBytes", "Request",
+	}
+	rows := [][]string{header}
+	for _, stat := range stats {
+		request := stat.Route
+		if stat.Method != "" {
+			request = stat.Method + " " + stat.Route
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(stat.Count),
+			formatFloat(stat.ReqTime.Sum),
+			formatFloat(stat.ReqTime.Avg()),
+			formatFloat(stat.ReqTime.Stddev()),
+			formatFloat(stat.ReqTime.Min),
+			formatFloat(stat.ReqTime.Percentile(50)),
+			formatFloat(stat.ReqTime.Percentile(90)),
+			formatFloat(stat.ReqTime.Percentile(95)),
+			formatFloat(stat.ReqTime.Percentile(99)),
+			formatFloat(stat.ReqTime.Max),
+			strconv.Itoa(stat.Status[2]),
+			strconv.Itoa(stat.Status[3]),
+			strconv.Itoa(stat.Status[4]),
+			strconv.Itoa(stat.Status[5]),
+			strconv.FormatFloat(stat.Size.Sum, 'f', 0, 64),
+			strconv.FormatFloat(stat.Size.Min, 'f', 0, 64),
+			strconv.FormatFloat(stat.Size.Avg(), 'f', 0, 64),
+			strconv.FormatFloat(stat.Size.Max, 'f', 0, 64),
+			request,
+		})
+	}
+	widths := columnWidths(rows)
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for idx, cell := range row {
+			if idx == len(row)-1 {
+				cells[idx] = cell
+			} else {
+				cells[idx] = fmt.Sprintf("%*s", widths[idx], cell)
+			}
+		}
+		b.WriteString(strings.Join(cells, "  "))
+		b.WriteString("\n")
+	}
+}
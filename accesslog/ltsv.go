@@ -0,0 +1,83 @@
+package accesslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Entry is a request recorded in the LTSV access log of nginx.
+type Entry struct {
+	Time    string
+	Host    string
+	Method  string
+	URI     string
+	Status  int
+	Size    int64
+	ReqTime float64
+}
+
+// ParseLTSV parses a line of the LTSV access log. It returns false if the line has neither uri nor req.
+func ParseLTSV(line string) (*Entry, bool) {
+	e := &Entry{}
+	var req string
+	for _, field := range strings.Split(line, "\t") {
+		idx := strings.IndexByte(field, ':')
+		if idx < 0 {
+			continue
+		}
+		value := field[idx+1:]
+		switch field[:idx] {
+		case "time":
+			e.Time = value
+		case "host":
+			e.Host = value
+		case "req":
+			req = value
+		case "method":
+			e.Method = value
+		case "uri":
+			e.URI = value
+		case "status":
+			e.Status, _ = strconv.Atoi(value)
+		case "size":
+			e.Size, _ = strconv.ParseInt(value, 10, 64)
+		case "reqtime":
+			e.ReqTime, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	// req is "GET /path HTTP/1.1" .
+	if fields := strings.Fields(req); len(fields) >= 2 {
+		if e.Method == "" {
+			e.Method = fields[0]
+		}
+		if e.URI == "" {
+			e.URI = fields[1]
+		}
+	}
+	if e.URI == "" {
+		return nil, false
+	}
+	return e, true
+}
+
+// Parse reads every entry of the LTSV access log and calls fn with it. Lines without uri are skipped.
+func Parse(r io.Reader, fn func(*Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		e, ok := ParseLTSV(strings.TrimRight(scanner.Text(), "\r"))
+		if !ok {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read access log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package accesslog
+
+import (
+	"sort"
+	"strings"
+)
+
+// RouteMatcher finds the echo route of a request path such as /users/:id for /users/10 .
+type RouteMatcher struct {
+	routes []route
+}
+
+type route struct {
+	path     string
+	segments []string
+}
+
+// NewRouteMatcher creates a matcher of the paths of echo.Routes() .
+// Like the router of echo, static segments take priority over :param , and :param over * .
+func NewRouteMatcher(paths []string) *RouteMatcher {
+	seen := map[string]struct{}{}
+	routes := make([]route, 0, len(paths))
+	for _, path := range paths {
+		if _, exists := seen[path]; exists {
+			continue
+		}
+		seen[path] = struct{}{}
+		routes = append(routes, route{path: path, segments: strings.Split(path, "/")})
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].before(routes[j])
+	})
+	return &RouteMatcher{routes: routes}
+}
+
+// before reports whether r is more specific than other.
+func (r route) before(other route) bool {
+	for i := 0; i < len(r.segments) && i < len(other.segments); i++ {
+		if a, b := segmentPriority(r.segments[i]), segmentPriority(other.segments[i]); a != b {
+			return a < b
+		}
+	}
+	return len(r.segments) > len(other.segments)
+}
+
+func segmentPriority(segment string) int {
+	switch {
+	case strings.HasPrefix(segment, "*"):
+		return 2
+	case strings.HasPrefix(segment, ":"):
+		return 1
+	}
+	return 0
+}
+
+func (r route) match(segments []string) bool {
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return len(r.segments) == len(segments)
+}
+
+// Match returns the route of uri. It returns the path of uri without the query if no route matches.
+func (m *RouteMatcher) Match(uri string) string {
+	path := uri
+	if idx := strings.IndexAny(path, "?#"); idx >= 0 {
+		path = path[:idx]
+	}
+	segments := strings.Split(path, "/")
+	for _, r := range m.routes {
+		if r.match(segments) {
+			return r.path
+		}
+	}
+	return path
+}
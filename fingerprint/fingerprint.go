@@ -0,0 +1,336 @@
+// Package fingerprint normalizes MySQL statements to group the same statements with different literals.
+package fingerprint
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	literalToken
+	symbolToken
+	// listToken is a list of literals such as (?+).
+	listToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// keywords are followed by a space before "(" . Other words before "(" are regarded as functions or tables.
+var keywords = map[string]struct{}{
+	"and": {}, "as": {}, "by": {}, "exists": {}, "from": {}, "having": {}, "in": {}, "into": {},
+	"is": {}, "join": {}, "not": {}, "on": {}, "or": {}, "select": {}, "set": {}, "union": {},
+	"using": {}, "values": {}, "value": {}, "when": {}, "where": {}, "then": {}, "else": {}, "all": {},
+}
+
+// operators are the symbols of multiple characters. Longer ones come first.
+var operators = []string{"<=>", "->>", "<=", ">=", "<>", "!=", ":=", "||", "&&", "<<", ">>", "->"}
+
+// Normalize returns the fingerprint of query.
+// Literals, NULL and placeholders become ? ( a leading sign of a number is a part of it ), so a prepared statement has the same fingerprint as the executed one.
+// Lists of literals such as IN (1, 2, 3) become (?+), the tuples of VALUES are collapsed into one,
+// comments are removed and words are lowercased with canonical spacing.
+func Normalize(query string) string {
+	tokens := tokenize(query)
+	tokens = collapseLists(tokens)
+	tokens = collapseValues(tokens)
+	return render(tokens)
+}
+
+// Hash returns the stable ID of the fingerprint in the same form as pt-query-digest.
+func Hash(fingerprint string) string {
+	sum := md5.Sum([]byte(fingerprint))
+	return fmt.Sprintf("0x%X", sum[8:])
+}
+
+// ID returns the hash of the fingerprint of query.
+func ID(query string) string {
+	return Hash(Normalize(query))
+}
+
+func tokenize(query string) []token {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '#' || isLineComment(query[i:]):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'' || c == '"':
+			i = skipQuoted(query, i, c)
+			tokens = append(tokens, token{kind: literalToken, text: "?"})
+		case c == '`':
+			end := skipQuoted(query, i, c)
+			tokens = append(tokens, token{kind: wordToken, text: strings.ToLower(query[i:end])})
+			i = end
+		case (c == 'x' || c == 'X' || c == 'b' || c == 'B') && i+1 < len(query) && query[i+1] == '\'':
+			i = skipQuoted(query, i+1, '\'')
+			tokens = append(tokens, token{kind: literalToken, text: "?"})
+		case c == '?':
+			i++
+			tokens = append(tokens, token{kind: literalToken, text: "?"})
+		case isNumberStart(query[i:], tokens):
+			i = skipNumber(query, i)
+			tokens = append(tokens, token{kind: literalToken, text: "?"})
+		case (c == '-' || c == '+') && isNumberStart(query[i+1:], tokens) && isSignPosition(tokens):
+			i = skipNumber(query, i+1)
+			tokens = append(tokens, token{kind: literalToken, text: "?"})
+		case isWordChar(c):
+			end := i
+			for end < len(query) && isWordChar(query[end]) {
+				end++
+			}
+			word := strings.ToLower(query[i:end])
+			if word == "null" {
+				tokens = append(tokens, token{kind: literalToken, text: "?"})
+			} else {
+				tokens = append(tokens, token{kind: wordToken, text: word})
+			}
+			i = end
+		default:
+			op := string(c)
+			for _, o := range operators {
+				if strings.HasPrefix(query[i:], o) {
+					op = o
+					break
+				}
+			}
+			tokens = append(tokens, token{kind: symbolToken, text: op})
+			i += len(op)
+		}
+	}
+	return tokens
+}
+
+// skipQuoted returns the position after the quoted text which starts at start. A quote is escaped by a backslash or doubled quotes.
+func skipQuoted(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+func isNumberStart(s string, tokens []token) bool {
+	if s == "" {
+		return false
+	}
+	return isDigit(s[0]) || s[0] == '.' && len(s) > 1 && isDigit(s[1]) && !followsWord(tokens)
+}
+
+// isSignPosition reports whether + or - after tokens is the sign of a number rather than a binary operator, such as in "= -1" or "(-1" .
+func isSignPosition(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	prev := tokens[len(tokens)-1]
+	switch prev.kind {
+	case symbolToken:
+		return prev.text != ")"
+	case wordToken:
+		_, isKeyword := keywords[prev.text]
+		return isKeyword
+	}
+	return false
+}
+
+func skipNumber(query string, start int) int {
+	i := start
+	if strings.HasPrefix(query[i:], "0x") || strings.HasPrefix(query[i:], "0X") {
+		i += 2
+		for i < len(query) && isHexDigit(query[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+		i++
+	}
+	if i < len(query) && (query[i] == 'e' || query[i] == 'E') {
+		j := i + 1
+		if j < len(query) && (query[j] == '+' || query[j] == '-') {
+			j++
+		}
+		if j < len(query) && isDigit(query[j]) {
+			i = j
+			for i < len(query) && isDigit(query[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// collapseLists replaces "(" ? , ? , ... ")" with (?+).
+func collapseLists(tokens []token) []token {
+	collapsed := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		if end := listEnd(tokens, i); end > 0 {
+			collapsed = append(collapsed, token{kind: listToken, text: "(?+)"})
+			i = end
+			continue
+		}
+		collapsed = append(collapsed, tokens[i])
+	}
+	return collapsed
+}
+
+// listEnd returns the position of ")" if a list of literals starts at start.
+func listEnd(tokens []token, start int) int {
+	if tokens[start].text != "(" {
+		return -1
+	}
+	expectLiteral := true
+	for i := start + 1; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case expectLiteral && t.kind == literalToken:
+			expectLiteral = false
+		case !expectLiteral && t.text == ",":
+			expectLiteral = true
+		case !expectLiteral && t.text == ")":
+			return i
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// collapseValues keeps only the first tuple of VALUES: "values" (?+) , (?+) , ... becomes "values" (?+) ,
+// and "values" (?, now()) , (?, now()) becomes "values" (?, now()) .
+func collapseValues(tokens []token) []token {
+	collapsed := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		collapsed = append(collapsed, tokens[i])
+		if t := tokens[i]; t.kind != wordToken || t.text != "values" && t.text != "value" {
+			continue
+		}
+		end := tupleEnd(tokens, i+1)
+		if end < 0 {
+			continue
+		}
+		collapsed = append(collapsed, tokens[i+1:end+1]...)
+		i = end
+		for i+2 < len(tokens) && tokens[i+1].text == "," {
+			next := tupleEnd(tokens, i+2)
+			if next < 0 {
+				break
+			}
+			i = next
+		}
+	}
+	return collapsed
+}
+
+// tupleEnd returns the position of the end of the tuple which starts at start: the list itself or the matching ")" .
+func tupleEnd(tokens []token, start int) int {
+	if start >= len(tokens) {
+		return -1
+	}
+	if tokens[start].kind == listToken {
+		return start
+	}
+	if tokens[start].text != "(" {
+		return -1
+	}
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func render(tokens []token) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 && needsSpace(tokens[i-1], t) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.text)
+	}
+	return b.String()
+}
+
+func needsSpace(prev, cur token) bool {
+	switch {
+	case prev.text == "(" || prev.text == ".":
+		return false
+	case cur.text == ")" || cur.text == "," || cur.text == "." || cur.text == ";":
+		return false
+	case cur.text == "(" || cur.kind == listToken:
+		if prev.kind != wordToken {
+			return prev.kind != symbolToken || prev.text == "," || isOperator(prev.text)
+		}
+		_, isKeyword := keywords[prev.text]
+		return isKeyword
+	}
+	return true
+}
+
+// isLineComment reports whether s starts with "--" followed by a whitespace or the end as MySQL requires.
+func isLineComment(s string) bool {
+	if !strings.HasPrefix(s, "--") {
+		return false
+	}
+	return len(s) == 2 || isSpace(s[2])
+}
+
+func isOperator(s string) bool {
+	return strings.ContainsAny(s, "=<>!+-*/%&|^")
+}
+
+func followsWord(tokens []token) bool {
+	return len(tokens) != 0 && tokens[len(tokens)-1].kind == wordToken
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isWordChar(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '$' || c >= 0x80
+}
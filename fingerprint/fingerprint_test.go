@@ -0,0 +1,117 @@
+package fingerprint
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	testcases := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{
+			name:   "number",
+			input:  "SELECT * FROM users WHERE id = 10",
+			expect: "select * from users where id = ?",
+		},
+		{
+			name:   "float and hex",
+			input:  "SELECT * FROM items WHERE price > 1.5e3 AND flags = 0xFF AND bits = b'101'",
+			expect: "select * from items where price > ? and flags = ? and bits = ?",
+		},
+		{
+			name:   "string",
+			input:  `SELECT * FROM users WHERE name = 'it''s' OR name = "a\"b"`,
+			expect: "select * from users where name = ? or name = ?",
+		},
+		{
+			name:   "comments",
+			input:  "SELECT /* hint */ * FROM users -- trailing\nWHERE id = 1 # mysql comment",
+			expect: "select * from users where id = ?",
+		},
+		{
+			name:   "whitespace",
+			input:  "SELECT\n\t*\n  FROM   users\r\n WHERE id=1;",
+			expect: "select * from users where id = ?;",
+		},
+		{
+			name:   "in list",
+			input:  "SELECT * FROM users WHERE id IN(1, 2,\n 3) AND name IN ('a')",
+			expect: "select * from users where id in (?+) and name in (?+)",
+		},
+		{
+			name:   "values",
+			input:  "INSERT INTO posts (user_id, body) VALUES (1, 'a'), (2, NULL),(3, 'c')",
+			expect: "insert into posts(user_id, body) values (?+)",
+		},
+		{
+			name:   "identifiers",
+			input:  "SELECT `t1`.`id`, t2.name FROM `T1` JOIN t2 ON t1.id = t2.t1_id LIMIT 10, 20",
+			expect: "select `t1`.`id`, t2.name from `t1` join t2 on t1.id = t2.t1_id limit ?, ?",
+		},
+		{
+			name:   "functions and operators",
+			input:  "SELECT COUNT(*) FROM users WHERE created_at >= NOW() AND score <> 1",
+			expect: "select count(*) from users where created_at >= now() and score <> ?",
+		},
+		{
+			name:   "placeholders",
+			input:  "SELECT * FROM users WHERE id = ? AND name IN (?, ?)",
+			expect: "select * from users where id = ? and name in (?+)",
+		},
+		{
+			name:   "signed numbers",
+			input:  "SELECT * FROM points WHERE x = -1.5e3 AND y IN (+1, -2) AND z = a - 1",
+			expect: "select * from points where x = ? and y in (?+) and z = a - ?",
+		},
+		{
+			name:   "values with functions",
+			input:  "INSERT INTO posts (user_id, created_at) VALUES (1, NOW()),(2,NOW())",
+			expect: "insert into posts(user_id, created_at) values (?, now())",
+		},
+		{
+			name:   "subquery",
+			input:  "SELECT * FROM users WHERE id = (SELECT user_id FROM posts WHERE id = 1)",
+			expect: "select * from users where id = (select user_id from posts where id = ?)",
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := Normalize(tt.input)
+			if actual != tt.expect {
+				t.Fatalf("expect: '%s' but actual: '%s'", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestID(t *testing.T) {
+	id := ID("SELECT * FROM users WHERE id = 1")
+	if id != ID("select *\nfrom users where id=2") {
+		t.Fatalf("expect the same id of the same fingerprint")
+	}
+	if id == ID("SELECT * FROM posts WHERE id = 1") {
+		t.Fatalf("expect the different id of the different fingerprint")
+	}
+	if id != Hash("select * from users where id = ?") || len(id) != 18 {
+		t.Fatalf("unexpected id: %s", id)
+	}
+}
+
+// a query-tracing wrapper sees the placeholders while the slow query log has the literals.
+func TestIDPlaceholder(t *testing.T) {
+	for _, tt := range []struct {
+		prepared string
+		executed string
+	}{
+		{prepared: "SELECT * FROM t WHERE id = ?", executed: "SELECT * FROM t WHERE id = 1"},
+		{prepared: "SELECT * FROM t WHERE id IN (?, ?, ?)", executed: "SELECT * FROM t WHERE id IN (1, 2)"},
+		{prepared: "UPDATE t SET x = ? WHERE id = ?", executed: "UPDATE t SET x = -1.5e3 WHERE id = 10"},
+		{prepared: "INSERT INTO t (a, b) VALUES (?, ?)", executed: "INSERT INTO t (a, b) VALUES (1, 'a'), (2, 'b')"},
+		{prepared: "INSERT INTO t (a, c) VALUES (?, NOW()), (?, NOW())", executed: "INSERT INTO t (a, c) VALUES (1, NOW())"},
+	} {
+		if prepared, executed := ID(tt.prepared), ID(tt.executed); prepared != executed {
+			t.Fatalf("expect the same id of %q and %q but got %s and %s", tt.prepared, tt.executed, prepared, executed)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package metric keeps the distribution of the values of an attribute such as the query time of slow queries or the response time of requests.
+package metric
+
+import (
+	"math"
+	"sort"
+)
+
+// Metric is the distribution of the values added to it.
+type Metric struct {
+	values []float64
+	sorted bool
+	Sum    float64
+	Min    float64
+	Max    float64
+}
+
+func New() *Metric {
+	return &Metric{Min: math.Inf(1), Max: math.Inf(-1)}
+}
+
+func (m *Metric) Add(v float64) {
+	m.values = append(m.values, v)
+	m.sorted = false
+	m.Sum += v
+	m.Min = math.Min(m.Min, v)
+	m.Max = math.Max(m.Max, v)
+}
+
+// Merge adds every value of other.
+func (m *Metric) Merge(other *Metric) {
+	for _, v := range other.values {
+		m.Add(v)
+	}
+}
+
+func (m *Metric) Count() int {
+	return len(m.values)
+}
+
+func (m *Metric) Avg() float64 {
+	if len(m.values) == 0 {
+		return 0
+	}
+	return m.Sum / float64(len(m.values))
+}
+
+// Percentile returns the value at p ( 0 - 100 ) by the nearest rank method.
+func (m *Metric) Percentile(p float64) float64 {
+	if len(m.values) == 0 {
+		return 0
+	}
+	if !m.sorted {
+		sort.Float64s(m.values)
+		m.sorted = true
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(m.values))))
+	if rank < 1 {
+		rank = 1
+	}
+	return m.values[rank-1]
+}
+
+func (m *Metric) Stddev() float64 {
+	if len(m.values) == 0 {
+		return 0
+	}
+	avg := m.Avg()
+	var sum float64
+	for _, v := range m.values {
+		sum += (v - avg) * (v - avg)
+	}
+	return math.Sqrt(sum / float64(len(m.values)))
+}
@@ -0,0 +1,28 @@
+package metric
+
+import "testing"
+
+func TestMetric(t *testing.T) {
+	m := New()
+	if m.Count() != 0 || m.Avg() != 0 || m.Percentile(50) != 0 || m.Stddev() != 0 {
+		t.Fatalf("unexpected empty metric: %+v", m)
+	}
+	for _, v := range []float64{4, 2, 8, 6} {
+		m.Add(v)
+	}
+	if m.Count() != 4 || m.Sum != 20 || m.Min != 2 || m.Max != 8 || m.Avg() != 5 {
+		t.Fatalf("unexpected metric: %+v", m)
+	}
+	if m.Percentile(50) != 4 || m.Percentile(95) != 8 || m.Percentile(0) != 2 {
+		t.Fatalf("unexpected percentile: %v %v %v", m.Percentile(50), m.Percentile(95), m.Percentile(0))
+	}
+	if stddev := m.Stddev(); stddev < 2.236 || stddev > 2.237 {
+		t.Fatalf("unexpected stddev: %v", stddev)
+	}
+	merged := New()
+	merged.Add(10)
+	merged.Merge(m)
+	if merged.Count() != 5 || merged.Sum != 30 || merged.Min != 2 || merged.Max != 10 || merged.Percentile(100) != 10 {
+		t.Fatalf("unexpected merged metric: %+v", merged)
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+
+	"github.com/labstack/echo/v4"
+)
+
+// pprof label keys attached by PprofLabels.
+const (
+	RouteLabel  = "route"
+	MethodLabel = "method"
+	StatusLabel = "status"
+)
+
+// PprofLabels attaches pprof labels of the route path ( c.Path() ) and the method to the CPU samples of each request.
+// The status class ( e.g. 5xx ) is unknown while the handler runs, so it is labeled only after the handler returns, which covers the error handler writing the response.
+// Like the Logger middleware of echo, the error of the handler is handled by c.Error and returned as is, so the error handler should skip the committed response.
+// The labels are used by profiler.Profiler to aggregate the CPU time by route.
+func PprofLabels() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			labels := pprof.Labels(RouteLabel, c.Path(), MethodLabel, req.Method)
+			var err error
+			pprof.Do(req.Context(), labels, func(ctx context.Context) {
+				c.SetRequest(req.WithContext(ctx))
+				err = next(c)
+				pprof.Do(ctx, pprof.Labels(StatusLabel, statusClass(c, err)), func(ctx context.Context) {
+					if err != nil {
+						c.SetRequest(c.Request().WithContext(ctx))
+						c.Error(err)
+					}
+				})
+			})
+			return err
+		}
+	}
+}
+
+func statusClass(c echo.Context, err error) string {
+	status := c.Response().Status
+	if err != nil && !c.Response().Committed {
+		status = http.StatusInternalServerError
+		if he, ok := err.(*echo.HTTPError); ok {
+			status = he.Code
+		}
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
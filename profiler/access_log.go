@@ -11,13 +11,13 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/goccy/echo-tools/accesslog"
 	"github.com/goccy/echo-tools/alp"
-	"github.com/goccy/echo-tools/gist"
-	"github.com/goccy/echo-tools/notifier"
 	"github.com/labstack/echo/v4"
 )
 
@@ -43,8 +43,25 @@ type AccessLogProfiler struct {
 	botName           string
 	githubToken       string
 	discordWebhookURL string
+	storage           Storage
+	auth              Credential
+	analyzer          AccessLogAnalyzer
+	sortKey           accesslog.SortKey
+	reverse           bool
+	kataribe          accesslog.KataribeConfig
+	artifacts         []Artifact
 }
 
+// AccessLogAnalyzer is the tool which makes the report of the access log per route.
+type AccessLogAnalyzer string
+
+const (
+	// ALPAnalyzer runs alp. It is the default analyzer.
+	ALPAnalyzer AccessLogAnalyzer = "alp"
+	// NativeAccessLogAnalyzer analyzes the access log in Go without alp. The report has the same columns as alp.
+	NativeAccessLogAnalyzer AccessLogAnalyzer = "native"
+)
+
 type AccessLogProfilerOption func(*AccessLogProfiler)
 
 func AccessLogOption(kataribeFile, alpOption, botName, webhookURL, githubToken string) AccessLogProfilerOption {
@@ -57,20 +74,67 @@ func AccessLogOption(kataribeFile, alpOption, botName, webhookURL, githubToken s
 	}
 }
 
+// AccessLogStorageOption stores the outputs of alp and kataribe in storage as access-log/<file name> on the host which analyzes the access log.
+// Profiler with StorageOption downloads them even if it runs on another host.
+func AccessLogStorageOption(storage Storage) AccessLogProfilerOption {
+	return func(p *AccessLogProfiler) {
+		p.storage = storage
+	}
+}
+
+// AccessLogAuthOption protects POST /debug/accessLog with cred. Stop sends the request with cred.
+func AccessLogAuthOption(cred Credential) AccessLogProfilerOption {
+	return func(p *AccessLogProfiler) {
+		p.auth = cred
+	}
+}
+
+// AccessLogAnalyzerOption selects the analyzer of the access log.
+func AccessLogAnalyzerOption(analyzer AccessLogAnalyzer) AccessLogProfilerOption {
+	return func(p *AccessLogProfiler) {
+		p.analyzer = analyzer
+	}
+}
+
+// AccessLogSortOption sorts the report of NativeAccessLogAnalyzer by key, in descending order if reverse is true.
+// The report is sorted by the total of reqtime in descending order by default. Use alpOption of AccessLogOption for alp.
+func AccessLogSortOption(key accesslog.SortKey, reverse bool) AccessLogProfilerOption {
+	return func(p *AccessLogProfiler) {
+		p.sortKey = key
+		p.reverse = reverse
+	}
+}
+
+// AccessLogKataribeOption configures the kataribe report of NativeAccessLogAnalyzer instead of the config file of kataribe.
+// The requests are grouped by the bundles of cfg and the echo routes.
+func AccessLogKataribeOption(cfg accesslog.KataribeConfig) AccessLogProfilerOption {
+	return func(p *AccessLogProfiler) {
+		p.kataribe = cfg
+	}
+}
+
 func NewAccessLogProfiler(e *echo.Echo, hostAddr string, opts ...AccessLogProfilerOption) *AccessLogProfiler {
 	p := &AccessLogProfiler{
 		echo:              e,
 		hostAddr:          hostAddr,
 		accessLogFileName: nginxAccessLog,
 	}
-	e.POST(accessLogEndpoint, echo.WrapHandler(&AccessLogHandler{}))
 	for _, opt := range opts {
 		opt(p)
 	}
+	e.POST(accessLogEndpoint, echo.WrapHandler(p.auth.handler(&AccessLogHandler{storage: p.storage})))
 	return p
 }
 
+func (p *AccessLogProfiler) Name() string {
+	return "access-log"
+}
+
 func (p *AccessLogProfiler) Start() error {
+	return p.StartContext(context.Background())
+}
+
+func (p *AccessLogProfiler) StartContext(ctx context.Context) error {
 	log.Print("[benchmark-access-log-profiler] Start")
 
 	now := time.Now().Format(accessLogFileFormat)
@@ -82,7 +146,8 @@ func (p *AccessLogProfiler) Start() error {
 	kataribeLogFile = fmt.Sprintf("%s.%s", "kataribe.log", now)
 
 	oldAccessLog := fmt.Sprintf("%s.%s", nginxAccessLog, now)
-	cmdMv := exec.Command(
+	cmdMv := exec.CommandContext(
+		ctx,
 		"sh", "-c", fmt.Sprintf("sudo mv %s %s", nginxAccessLog, oldAccessLog),
 	)
 	mvOut, err := cmdMv.CombinedOutput()
@@ -90,7 +155,8 @@ func (p *AccessLogProfiler) Start() error {
 		return fmt.Errorf("failed to mv access.log: %s: %w", string(mvOut), err)
 	}
 
-	cmdRot := exec.Command(
+	cmdRot := exec.CommandContext(
+		ctx,
 		"sh", "-c", "sudo nginx -s reopen",
 	)
 	rotOut, err := cmdRot.CombinedOutput()
@@ -101,13 +167,30 @@ func (p *AccessLogProfiler) Start() error {
 }
 
 type AccessLogRequest struct {
-	FileName          string   `json:"filename"`
-	KataribeConfPath  string   `json:"kataribeConfPath"`
-	ALPOption         string   `json:"alpOption"`
-	Routes            []string `json:"routes"`
-	BotName           string   `json:"botName"`
-	GitHubToken       string   `json:"githubToken"`
-	DiscordWebhookURL string   `json:"discordWebhookURL"`
+	FileName          string                   `json:"filename"`
+	Title             string                   `json:"title"`
+	KataribeConfPath  string                   `json:"kataribeConfPath"`
+	ALPOption         string                   `json:"alpOption"`
+	Analyzer          string                   `json:"analyzer"`
+	Sort              string                   `json:"sort"`
+	Reverse           bool                     `json:"reverse"`
+	Kataribe          accesslog.KataribeConfig `json:"kataribe"`
+	Routes            []string                 `json:"routes"`
+	BotName           string                   `json:"botName"`
+	GitHubToken       string                   `json:"githubToken"`
+	DiscordWebhookURL string                   `json:"discordWebhookURL"`
+}
+
+// gistTitle returns the title of the run if it is specified, or the name of the access log.
+func (r *AccessLogRequest) gistTitle(kind string) string {
+	if r.Title == "" {
+		return r.FileName
+	}
+	return reportTitle(kind, r.Title)
+}
+
+type AccessLogResponse struct {
+	Artifacts []Artifact `json:"artifacts"`
 }
 
 func (p *AccessLogProfiler) requestURL() string {
@@ -116,14 +199,27 @@ func (p *AccessLogProfiler) requestURL() string {
 }
 
 func (p *AccessLogProfiler) Stop() error {
+	return p.StopContext(context.Background())
+}
+
+func (p *AccessLogProfiler) StopContext(ctx context.Context) error {
 	routes := make([]string, 0, len(p.echo.Routes()))
 	for _, r := range p.echo.Routes() {
 		routes = append(routes, r.Path)
 	}
+	var title string
+	if run, ok := RunFromContext(ctx); ok {
+		title = run.Title()
+	}
 	b, err := json.Marshal(&AccessLogRequest{
 		FileName:          p.accessLogFileName,
+		Title:             title,
 		KataribeConfPath:  p.kataribeConfPath,
 		ALPOption:         p.alpOption,
+		Analyzer:          string(p.analyzer),
+		Sort:              string(p.sortKey),
+		Reverse:           p.reverse,
+		Kataribe:          p.kataribe,
 		Routes:            routes,
 		BotName:           p.botName,
 		GitHubToken:       p.githubToken,
@@ -133,60 +229,100 @@ func (p *AccessLogProfiler) Stop() error {
 		return fmt.Errorf("failed to encode access log: %w", err)
 	}
 	url := p.requestURL()
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(b))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(b))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/json")
+	p.auth.setTo(req)
 	httpClient := new(http.Client)
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to post %s: %w", url, err)
 	}
-	buf, _ := io.ReadAll(resp.Body)
-	fmt.Printf("result: %s\n", string(buf))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		buf, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to analyze access log: %s", string(buf))
+	}
+	var res AccessLogResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("failed to decode access log response: %w", err)
+	}
+	p.artifacts = res.Artifacts
 	return nil
 }
 
-type AccessLogHandler struct{}
+func (p *AccessLogProfiler) Artifacts() []Artifact {
+	return p.artifacts
+}
+
+type AccessLogHandler struct {
+	storage Storage
+}
 
 func (h *AccessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if err := h.handle(r.Context(), r.Body); err != nil {
+	artifacts, err := h.handle(r.Context(), r.Body)
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintln(w, err.Error())
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&AccessLogResponse{Artifacts: artifacts})
 }
 
 var (
-	alpCommandTmpl      = `sudo alp ltsv --file %s -r -m "%s" %s > %s`
-	kataribeCommandTmpl = `sudo cat %s | kataribe -conf %s > %s`
+	alpCommandTmpl           = `sudo alp ltsv --file %s -r -m "%s" %s > %s`
+	kataribeCommandTmpl      = `sudo cat %s | kataribe -conf %s > %s`
+	accessLogReadCommandTmpl = `sudo cat %s`
 )
 
-func (h *AccessLogHandler) handle(ctx context.Context, body io.Reader) error {
+func (h *AccessLogHandler) handle(ctx context.Context, body io.Reader) ([]Artifact, error) {
 	var req AccessLogRequest
 	if err := json.NewDecoder(body).Decode(&req); err != nil {
-		return fmt.Errorf("failed to decode access log request: %w", err)
+		return nil, fmt.Errorf("failed to decode access log request: %w", err)
 	}
 
 	if req.FileName == "" {
-		return fmt.Errorf("failed to find access-log filename")
+		return nil, fmt.Errorf("failed to find access-log filename")
 	}
 
-	if err := execALP(ctx, req); err != nil {
-		return err
+	alpArtifact, err := execALP(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.store(ctx, &alpArtifact); err != nil {
+		return []Artifact{alpArtifact}, err
 	}
 
-	ltsvToWithTime(alpAccessLog, kataribeAccessLog)
+	kataribeReq := req
+	if AccessLogAnalyzer(req.Analyzer) != NativeAccessLogAnalyzer {
+		// kataribe reads the access log in the with_time format.
+		ltsvToWithTime(alpAccessLog, kataribeAccessLog)
+		kataribeReq.FileName = kataribeAccessLog
+	}
+	kataribeArtifact, err := execKataribe(ctx, kataribeReq)
+	if err != nil {
+		return []Artifact{alpArtifact}, err
+	}
+	if err := h.store(ctx, &kataribeArtifact); err != nil {
+		return []Artifact{alpArtifact, kataribeArtifact}, err
+	}
+	return []Artifact{alpArtifact, kataribeArtifact}, nil
+}
 
-	return execKataribe(ctx, AccessLogRequest{
-		FileName:          kataribeAccessLog,
-		KataribeConfPath:  req.KataribeConfPath,
-		ALPOption:         req.ALPOption,
-		Routes:            req.Routes,
-		BotName:           req.BotName,
-		GitHubToken:       req.GitHubToken,
-		DiscordWebhookURL: req.DiscordWebhookURL,
-	})
+// store stores the report in storage and sets the key to artifact.
+func (h *AccessLogHandler) store(ctx context.Context, artifact *Artifact) error {
+	if h.storage == nil {
+		return nil
+	}
+	key := path.Join("access-log", filepath.Base(artifact.Path))
+	if err := putFile(ctx, h.storage, key, artifact.Path); err != nil {
+		return err
+	}
+	artifact.Key = key
+	return nil
 }
 
 /*
@@ -294,75 +430,111 @@ func ltsvToWithTime(inputPath, outputPath string) error {
 	return nil
 }
 
-func execKataribe(ctx context.Context, req AccessLogRequest) error {
+func execKataribe(ctx context.Context, req AccessLogRequest) (Artifact, error) {
 	tempDir := os.TempDir()
 	kataribeFile := filepath.Join(tempDir, kataribeLogFile)
-	cmd := exec.Command(
-		"sh", "-c", fmt.Sprintf(kataribeCommandTmpl, req.FileName, req.KataribeConfPath, kataribeFile),
-	)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to exec kataribe: %s: %w", string(out), err)
+	if AccessLogAnalyzer(req.Analyzer) == NativeAccessLogAnalyzer {
+		if err := writeKataribeReport(req, kataribeFile); err != nil {
+			return Artifact{}, err
+		}
+	} else {
+		cmd := exec.Command(
+			"sh", "-c", fmt.Sprintf(kataribeCommandTmpl, req.FileName, req.KataribeConfPath, kataribeFile),
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return Artifact{}, fmt.Errorf("failed to exec kataribe: %s: %w", string(out), err)
+		}
 	}
+	artifact := Artifact{Name: "kataribe", Path: kataribeFile}
 	log.Print("[benchmark-access-log-profiler] send to gist")
 	if req.GitHubToken == "" || req.DiscordWebhookURL == "" {
-		return nil
-	}
-	client, err := gist.NewClient(ctx, req.GitHubToken)
-	if err != nil {
-		return fmt.Errorf("failed to create gist client: %w", err)
-	}
-	url, err := client.UploadFile(ctx, req.FileName, kataribeFile)
-	if err != nil {
-		return fmt.Errorf("failed to upload access-log: %w", err)
-	}
-	botName := req.BotName
-	if botName == "" {
-		botName = "bot"
+		return artifact, nil
 	}
-	discordClient := notifier.NewDiscordClient(req.DiscordWebhookURL)
-	if err := discordClient.Post(&notifier.DiscordMessage{
-		Username: botName,
-		Content:  fmt.Sprintf("access-log kataribe: %s", url),
-	}); err != nil {
-		return fmt.Errorf("failed to post message to discord: %w", err)
-	}
-	return nil
+	url, err := shareReport(ctx, req.gistTitle("access-log kataribe"), reportTitle("access-log kataribe", req.Title), kataribeFile, req.BotName, req.DiscordWebhookURL, req.GitHubToken)
+	artifact.URL = url
+	return artifact, err
 }
 
-func execALP(ctx context.Context, req AccessLogRequest) error {
+func execALP(ctx context.Context, req AccessLogRequest) (Artifact, error) {
 	tempDir := os.TempDir()
 	alpFile := filepath.Join(tempDir, alpLogFile)
-	matchingGroups := alp.ConvertEchoRoutes(req.Routes)
-	cmd := exec.Command(
-		"sh", "-c", fmt.Sprintf(alpCommandTmpl, req.FileName, matchingGroups, req.ALPOption, alpFile),
-	)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to exec alp: %s: %w", string(out), err)
+	switch AccessLogAnalyzer(req.Analyzer) {
+	case "", ALPAnalyzer:
+		matchingGroups := alp.ConvertEchoRoutes(req.Routes)
+		cmd := exec.Command(
+			"sh", "-c", fmt.Sprintf(alpCommandTmpl, req.FileName, matchingGroups, req.ALPOption, alpFile),
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return Artifact{}, fmt.Errorf("failed to exec alp: %s: %w", string(out), err)
+		}
+	case NativeAccessLogAnalyzer:
+		if err := writeAccessLogTable(req, alpFile); err != nil {
+			return Artifact{}, err
+		}
+	default:
+		return Artifact{}, fmt.Errorf("failed to find access log analyzer: %s", req.Analyzer)
 	}
+	artifact := Artifact{Name: "alp", Path: alpFile}
 	log.Print("[benchmark-access-log-profiler] send to gist")
 	if req.GitHubToken == "" || req.DiscordWebhookURL == "" {
-		return nil
+		return artifact, nil
 	}
-	client, err := gist.NewClient(ctx, req.GitHubToken)
-	if err != nil {
-		return fmt.Errorf("failed to create gist client: %w", err)
+	url, err := shareReport(ctx, req.gistTitle("access-log alp"), reportTitle("access-log alp", req.Title), alpFile, req.BotName, req.DiscordWebhookURL, req.GitHubToken)
+	artifact.URL = url
+	return artifact, err
+}
+
+// writeAccessLogTable analyzes the access log by the accesslog package and writes the table in the same columns as alp to file.
+func writeAccessLogTable(req AccessLogRequest, file string) error {
+	sortKey := accesslog.SortBySum
+	reverse := true
+	if req.Sort != "" {
+		key, err := accesslog.ParseSortKey(req.Sort)
+		if err != nil {
+			return fmt.Errorf("failed to parse sort key: %w", err)
+		}
+		sortKey = key
+		reverse = req.Reverse
+	}
+	var stats accesslog.Stats
+	if err := readPrivilegedFile(accessLogReadCommandTmpl, req.FileName, func(r io.Reader) error {
+		analyzed, err := accesslog.Analyze(r, req.Routes)
+		stats = analyzed
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to analyze access log: %w", err)
 	}
-	url, err := client.UploadFile(ctx, req.FileName, alpFile)
+	stats.Sort(sortKey, reverse)
+	f, err := os.Create(file)
 	if err != nil {
-		return fmt.Errorf("failed to upload access-log: %w", err)
+		return fmt.Errorf("failed to create %s: %w", file, err)
 	}
-	botName := req.BotName
-	if botName == "" {
-		botName = "bot"
+	defer f.Close()
+	if err := stats.WriteTable(f); err != nil {
+		return fmt.Errorf("failed to write access log table: %w", err)
 	}
-	discordClient := notifier.NewDiscordClient(req.DiscordWebhookURL)
-	if err := discordClient.Post(&notifier.DiscordMessage{
-		Username: botName,
-		Content:  fmt.Sprintf("access-log alp: %s", url),
+	return nil
+}
+
+// writeKataribeReport analyzes the LTSV access log by the accesslog package and writes the report in the format of kataribe to file.
+func writeKataribeReport(req AccessLogRequest, file string) error {
+	var report *accesslog.KataribeReport
+	if err := readPrivilegedFile(accessLogReadCommandTmpl, req.FileName, func(r io.Reader) error {
+		analyzed, err := accesslog.AnalyzeKataribe(r, req.Routes, req.Kataribe)
+		report = analyzed
+		return err
 	}); err != nil {
-		return fmt.Errorf("failed to post message to discord: %w", err)
+		return fmt.Errorf("failed to analyze access log: %w", err)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", file, err)
+	}
+	defer f.Close()
+	if err := report.Write(f); err != nil {
+		return fmt.Errorf("failed to write kataribe report: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,19 @@
+package profiler
+
+import "context"
+
+func ReplaceAccessLogReadCommandTemplate() {
+	accessLogReadCommandTmpl = "cat %s"
+}
+
+// ExecALP writes the report of the access log to os.TempDir()/logFile .
+func ExecALP(ctx context.Context, req AccessLogRequest, logFile string) (Artifact, error) {
+	alpLogFile = logFile
+	return execALP(ctx, req)
+}
+
+// SetAccessLogReportFiles sets the names of the reports in os.TempDir() which Start sets.
+func SetAccessLogReportFiles(alpFile, kataribeFile string) {
+	alpLogFile = alpFile
+	kataribeLogFile = kataribeFile
+}
@@ -0,0 +1,124 @@
+package profiler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/echo-tools/accesslog"
+	profilertools "github.com/goccy/echo-tools/profiler"
+	"github.com/labstack/echo/v4"
+)
+
+func writeTestAccessLog(t *testing.T) string {
+	accessLog := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(accessLog, []byte(strings.Join([]string{
+		"time:02/Jan/2024:03:04:05 +0900\treq:GET /users/1 HTTP/1.1\tstatus:200\tmethod:GET\turi:/users/1\tsize:100\treqtime:0.100",
+		"time:02/Jan/2024:03:04:06 +0900\treq:GET /users/2 HTTP/1.1\tstatus:200\tmethod:GET\turi:/users/2\tsize:300\treqtime:0.300",
+		"time:02/Jan/2024:03:04:07 +0900\treq:POST /users HTTP/1.1\tstatus:201\tmethod:POST\turi:/users\tsize:10\treqtime:0.050",
+	}, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	profilertools.ReplaceAccessLogReadCommandTemplate()
+	return accessLog
+}
+
+func TestAccessLogNativeAnalyzer(t *testing.T) {
+	accessLog := writeTestAccessLog(t)
+
+	logFile := fmt.Sprintf("alp.log.native.%d", time.Now().UnixNano())
+	artifact, err := profilertools.ExecALP(context.Background(), profilertools.AccessLogRequest{
+		FileName: accessLog,
+		Analyzer: string(profilertools.NativeAccessLogAnalyzer),
+		Sort:     "count",
+		Reverse:  true,
+		Routes:   []string{"/users", "/users/:id"},
+	}, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(artifact.Path)
+	if artifact.Name != "alp" {
+		t.Fatalf("unexpected artifact: %+v", artifact)
+	}
+	f, err := os.Open(artifact.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// the trend page reads the report in the same way as alp.
+	latencies, err := profilertools.ParseALPTable(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latencies) != 2 {
+		t.Fatalf("unexpected latencies: %d", len(latencies))
+	}
+	if latencies[0].Method != "GET" || latencies[0].URI != "/users/:id" || latencies[0].Count != 2 || latencies[0].Avg != 0.2 {
+		t.Fatalf("unexpected latency: %+v", latencies[0])
+	}
+
+	if _, err := profilertools.ExecALP(context.Background(), profilertools.AccessLogRequest{
+		FileName: accessLog,
+		Analyzer: string(profilertools.NativeAccessLogAnalyzer),
+		Sort:     "unknown",
+	}, logFile); err == nil {
+		t.Fatal("expected error for unknown sort key")
+	}
+}
+
+func TestAccessLogNativeKataribe(t *testing.T) {
+	accessLog := writeTestAccessLog(t)
+	now := time.Now().UnixNano()
+	profilertools.SetAccessLogReportFiles(fmt.Sprintf("alp.log.native.%d", now), fmt.Sprintf("kataribe.log.native.%d", now))
+
+	e := echo.New()
+	server := httptest.NewServer(e)
+	defer server.Close()
+	profilertools.NewAccessLogProfiler(e, server.URL, profilertools.AccessLogAnalyzerOption(profilertools.NativeAccessLogAnalyzer))
+	b, err := json.Marshal(&profilertools.AccessLogRequest{
+		FileName: accessLog,
+		Analyzer: string(profilertools.NativeAccessLogAnalyzer),
+		Routes:   []string{"/users/:id"},
+		Kataribe: accesslog.KataribeConfig{
+			Bundles: []accesslog.Bundle{{Name: "create user", Regexp: "^POST /users$"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Post(server.URL+"/debug/accessLog", "application/json", strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", res.StatusCode)
+	}
+	var v profilertools.AccessLogResponse
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Artifacts) != 2 || v.Artifacts[0].Name != "alp" || v.Artifacts[1].Name != "kataribe" {
+		t.Fatalf("unexpected artifacts: %+v", v.Artifacts)
+	}
+	for _, artifact := range v.Artifacts {
+		defer os.Remove(artifact.Path)
+	}
+	report, err := os.ReadFile(v.Artifacts[1].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Top 2 Sort By Total", "  GET /users/:id\n", "  create user\n", "TOP 3 Slow Requests"} {
+		if !strings.Contains(string(report), expected) {
+			t.Fatalf("report doesn't contain %q:\n%s", expected, report)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package profiler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	authCookieName = "profiler_token"
+	authTokenQuery = "token"
+)
+
+// Credential protects the web UI and the routes of the sub profilers by a shared token or basic auth.
+// A request is authorized by `Authorization: Bearer <token>` or the basic auth of User and Password.
+// Browsers can open the web UI by /?token=<token> once, which stores the token in a cookie.
+type Credential struct {
+	Token    string
+	User     string
+	Password string
+}
+
+func TokenCredential(token string) Credential {
+	return Credential{Token: token}
+}
+
+func BasicAuthCredential(user, password string) Credential {
+	return Credential{User: user, Password: password}
+}
+
+func (c Credential) enabled() bool {
+	return c.Token != "" || c.basicAuth()
+}
+
+func (c Credential) basicAuth() bool {
+	return c.User != "" || c.Password != ""
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (c Credential) authorized(r *http.Request) bool {
+	if c.basicAuth() {
+		user, password, ok := r.BasicAuth()
+		userOK := secureEqual(user, c.User)
+		passwordOK := secureEqual(password, c.Password)
+		if ok && userOK && passwordOK {
+			return true
+		}
+	}
+	if c.Token != "" {
+		header := r.Header.Get(echo.HeaderAuthorization)
+		if strings.HasPrefix(header, "Bearer ") && secureEqual(strings.TrimPrefix(header, "Bearer "), c.Token) {
+			return true
+		}
+		if cookie, err := r.Cookie(authCookieName); err == nil && secureEqual(cookie.Value, c.Token) {
+			return true
+		}
+	}
+	return false
+}
+
+// setTo adds the credential to the request sent to the routes protected by it.
+func (c Credential) setTo(r *http.Request) {
+	switch {
+	case c.Token != "":
+		r.Header.Set(echo.HeaderAuthorization, "Bearer "+c.Token)
+	case c.basicAuth():
+		r.SetBasicAuth(c.User, c.Password)
+	}
+}
+
+// handler returns next as is if the credential is empty.
+func (c Credential) handler(next http.Handler) http.Handler {
+	if !c.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if c.Token != "" && query.Get(authTokenQuery) != "" && secureEqual(query.Get(authTokenQuery), c.Token) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     authCookieName,
+				Value:    c.Token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+			query.Del(authTokenQuery)
+			u := *r.URL
+			u.RawQuery = query.Encode()
+			http.Redirect(w, r, u.RequestURI(), http.StatusFound)
+			return
+		}
+		if !c.authorized(r) {
+			if c.basicAuth() {
+				w.Header().Set("WWW-Authenticate", `Basic realm="profiler"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthOption protects the web UI served by ListenAndServe with cred.
+func AuthOption(cred Credential) ProfilerOption {
+	return func(p *Profiler) {
+		p.auth = cred
+	}
+}
@@ -0,0 +1,7 @@
+package profiler
+
+import "net/http"
+
+func AuthHandler(cred Credential, h http.Handler) http.Handler {
+	return cred.handler(h)
+}
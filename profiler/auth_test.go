@@ -0,0 +1,121 @@
+package profiler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	token := profilertools.AuthHandler(profilertools.TokenCredential("secret"), ok)
+	basic := profilertools.AuthHandler(profilertools.BasicAuthCredential("isucon", "pass"), ok)
+	serve := func(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(token, httptest.NewRequest(http.MethodGet, "/", nil)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 but got %d", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+	if rec := serve(token, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rec.Code)
+	}
+	if rec := serve(token, httptest.NewRequest(http.MethodGet, "/1/?token=invalid", nil)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 but got %d", rec.Code)
+	}
+	// the token in the query is stored in the cookie.
+	rec := serve(token, httptest.NewRequest(http.MethodGet, "/1/?token=secret&si=cpu", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/1/?si=cpu" {
+		t.Fatalf("unexpected redirect: %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	req = httptest.NewRequest(http.MethodGet, "/1/?si=cpu", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	if rec := serve(token, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with cookie but got %d", rec.Code)
+	}
+
+	rec = serve(basic, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized || !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Basic") {
+		t.Fatalf("expected basic auth challenge but got %d %v", rec.Code, rec.Header())
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("isucon", "invalid")
+	if rec := serve(basic, req); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 but got %d", rec.Code)
+	}
+	req.SetBasicAuth("isucon", "pass")
+	if rec := serve(basic, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rec.Code)
+	}
+}
+
+func TestAccessLogAuth(t *testing.T) {
+	e := echo.New()
+	server := httptest.NewServer(e)
+	defer server.Close()
+	p := profilertools.NewAccessLogProfiler(e, server.URL, profilertools.AccessLogAuthOption(profilertools.TokenCredential("secret")))
+	res, err := http.Post(server.URL+"/debug/accessLog", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 but got %d", res.StatusCode)
+	}
+	// Stop sends the token. The request reaches the handler and fails to run alp in this environment.
+	if err := p.Stop(); err == nil || strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTraceViewerAuth(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("viewer " + r.URL.Path))
+	}))
+	defer backend.Close()
+	p := profilertools.NewProfiler(t.TempDir(), profilertools.AuthOption(profilertools.TokenCredential("secret")))
+	h, err := profilertools.ServeHandler(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(path string, authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorized {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := serve("/trace-viewer/", true); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before the viewer is opened but got %d", rec.Code)
+	}
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profilertools.SetTraceViewerBackend(p, u)
+	if rec := serve("/trace-viewer/", false); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 but got %d", rec.Code)
+	}
+	if rec := serve("/trace-viewer/", true); rec.Code != http.StatusOK || rec.Body.String() != "viewer /" {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+	// the pages of the viewer link to absolute paths.
+	if rec := serve("/jsontrace?start=0", true); rec.Code != http.StatusOK || rec.Body.String() != "viewer /jsontrace" {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+}
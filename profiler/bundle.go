@@ -0,0 +1,255 @@
+package profiler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const (
+	bundleArtifactPrefix = "artifact"
+	// maxBundleManifestSize is the limit of the manifest read from a bundle.
+	maxBundleManifestSize = 1 << 20
+)
+
+var bundleArtifactNameRe = regexp.MustCompile(`[^A-Za-z0-9.-]`)
+
+// bundleArtifactFileName is the name of a report which was stored out of baseDir, such as the output of alp, in a bundle.
+func bundleArtifactFileName(currentTime, name string) string {
+	return fmt.Sprintf("%s_%s_%s.txt", bundleArtifactPrefix, currentTime, bundleArtifactNameRe.ReplaceAllString(name, "-"))
+}
+
+func bundleFileName(id string) string {
+	return fmt.Sprintf("run_%s.tar.gz", id)
+}
+
+// ExportRun writes the run stored in baseDir as a tar.gz bundle to w.
+// The bundle contains every profile, the reports recorded in the manifest and the manifest whose paths are relative to the bundle.
+func (p *Profiler) ExportRun(id string, w io.Writer) error {
+	p.stateMu.Lock()
+	state, currentTime := p.state, p.currentTime
+	p.stateMu.Unlock()
+	if state != StateIdle && state != StateContinuous && currentTime == id {
+		return &StateError{Op: "export run of", State: state}
+	}
+	if err := p.createBaseDirIfNotExists(); err != nil {
+		return err
+	}
+	runs, err := listStoredRuns(p.baseDir)
+	if err != nil {
+		return err
+	}
+	var stored *storedRun
+	for _, r := range runs {
+		if r.id == id {
+			stored = r
+			break
+		}
+	}
+	if stored == nil {
+		return fmt.Errorf("failed to find run %s", id)
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	manifestPath := filepath.Join(p.baseDir, manifestFileName(id))
+	files := map[string]struct{}{}
+	for _, file := range stored.files {
+		files[file] = struct{}{}
+		if file == manifestPath {
+			continue
+		}
+		if err := addBundleFile(tw, file, filepath.Base(file)); err != nil {
+			return err
+		}
+	}
+	manifest, err := readManifest(manifestPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if manifest != nil {
+		bundled := *manifest
+		bundled.Profiles = make(map[string]string, len(manifest.Profiles))
+		for typ, path := range manifest.Profiles {
+			bundled.Profiles[typ] = filepath.Base(path)
+		}
+		bundled.Artifacts = make([]Artifact, 0, len(manifest.Artifacts))
+		for _, artifact := range manifest.Artifacts {
+			if _, exists := files[artifact.Path]; exists {
+				artifact.Path = filepath.Base(artifact.Path)
+			} else if _, err := os.Stat(artifact.Path); artifact.Path != "" && err == nil {
+				name := bundleArtifactFileName(id, artifact.Name)
+				if err := addBundleFile(tw, artifact.Path, name); err != nil {
+					return err
+				}
+				artifact.Path = name
+			} else {
+				// the report is not on this host.
+				artifact.Path = ""
+			}
+			bundled.Artifacts = append(bundled.Artifacts, artifact)
+		}
+		b, err := json.MarshalIndent(&bundled, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		if err := addBundleEntry(tw, manifestFileName(id), int64(len(b)), time.Now(), bytes.NewReader(b)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+func addBundleFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	return addBundleEntry(tw, name, info.Size(), info.ModTime(), f)
+}
+
+func addBundleEntry(tw *tar.Writer, name string, size int64, modTime time.Time, r io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: modTime,
+	}); err != nil {
+		return fmt.Errorf("failed to write header of %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %w", name, err)
+	}
+	return nil
+}
+
+// ImportRun unpacks the bundle written by ExportRun into baseDir and returns the id of the run.
+// The run is shown in the web UI like a local run and stored in Storage if StorageOption is specified. It fails if the run already exists in baseDir.
+func (p *Profiler) ImportRun(r io.Reader) (string, error) {
+	if err := p.createBaseDirIfNotExists(); err != nil {
+		return "", err
+	}
+	id, manifest, err := p.extractBundle(r)
+	if err != nil {
+		return "", err
+	}
+	pprofPath := filepath.Join(p.baseDir, cpuProfileFileName(id))
+	if manifest != nil {
+		relocateManifest(manifest, p.baseDir)
+		if err := manifest.write(filepath.Join(p.baseDir, manifestFileName(id))); err != nil {
+			removeStoredRun(p.baseDir, id)
+			return "", err
+		}
+	}
+	if p.storage != nil {
+		if err := p.pushRun(context.Background(), id); err != nil {
+			return id, err
+		}
+	}
+	p.mountMu.Lock()
+	served := p.served
+	p.mountMu.Unlock()
+	if served {
+		if err := p.addProfileResult(pprofPath, nil); err != nil {
+			return id, fmt.Errorf("failed to add profile result: %w", err)
+		}
+	}
+	return id, nil
+}
+
+// extractBundle writes the files of the bundle except the manifest into baseDir. It removes them if the bundle is invalid.
+func (p *Profiler) extractBundle(r io.Reader) (string, *Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	defer gr.Close()
+	var (
+		id       string
+		manifest *Manifest
+		written  []string
+	)
+	cleanup := func(err error) (string, *Manifest, error) {
+		for _, path := range written {
+			os.Remove(path)
+		}
+		return "", nil, err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cleanup(fmt.Errorf("failed to read bundle: %w", err))
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := header.Name
+		matched := runFileRe.FindStringSubmatch(name)
+		if filepath.Base(name) != name || matched == nil {
+			return cleanup(fmt.Errorf("invalid file %s in bundle", name))
+		}
+		prefix := matched[1]
+		if _, exists := runFilePrefixes[prefix]; !exists {
+			return cleanup(fmt.Errorf("invalid file %s in bundle", name))
+		}
+		switch {
+		case id == "":
+			id = matched[2]
+			if _, err := os.Stat(filepath.Join(p.baseDir, cpuProfileFileName(id))); err == nil {
+				return cleanup(fmt.Errorf("failed to import run %s: run already exists", id))
+			}
+		case id != matched[2]:
+			return cleanup(fmt.Errorf("failed to import bundle: bundle contains several runs"))
+		}
+		if prefix == "manifest" {
+			var m Manifest
+			if err := json.NewDecoder(io.LimitReader(tr, maxBundleManifestSize)).Decode(&m); err != nil {
+				return cleanup(fmt.Errorf("failed to decode manifest %s: %w", name, err))
+			}
+			manifest = &m
+			continue
+		}
+		path := filepath.Join(p.baseDir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			return cleanup(fmt.Errorf("failed to create file %s: %w", path, err))
+		}
+		written = append(written, path)
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return cleanup(fmt.Errorf("failed to write file %s: %w", path, err))
+		}
+		if err := f.Close(); err != nil {
+			return cleanup(fmt.Errorf("failed to write file %s: %w", path, err))
+		}
+	}
+	if id == "" {
+		return cleanup(fmt.Errorf("failed to import bundle: bundle is empty"))
+	}
+	if _, err := os.Stat(filepath.Join(p.baseDir, cpuProfileFileName(id))); err != nil {
+		return cleanup(fmt.Errorf("failed to import run %s: bundle has no cpu profile", id))
+	}
+	return id, manifest, nil
+}
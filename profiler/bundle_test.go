@@ -0,0 +1,127 @@
+package profiler_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+type reportProfiler struct {
+	path string
+}
+
+func (p *reportProfiler) Start() error { return nil }
+func (p *reportProfiler) Stop() error {
+	return os.WriteFile(p.path, []byte("| COUNT | METHOD | URI |"), 0o644)
+}
+
+func (p *reportProfiler) Artifacts() []profilertools.Artifact {
+	return []profilertools.Artifact{
+		{Name: "alp", Path: p.path},
+		{Name: "kataribe", URL: "https://gist.github.com/kataribe"},
+	}
+}
+
+func TestProfilerExportImportRun(t *testing.T) {
+	src := profilertools.NewProfiler(t.TempDir())
+	src.AddProfiler(&reportProfiler{path: filepath.Join(t.TempDir(), "alp.log")})
+	if err := src.Start(profilertools.RunNameOption("bundle")); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetScore(1000, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := src.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := runs[0].ID
+	var bundle bytes.Buffer
+	if err := src.ExportRun(id, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.ExportRun("unknown", &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error for unknown run")
+	}
+
+	dir := t.TempDir()
+	dst := profilertools.NewProfiler(dir)
+	imported, err := dst.ImportRun(bytes.NewReader(bundle.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != id {
+		t.Fatalf("expected %s but got %s", id, imported)
+	}
+	runs, err = dst.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Manifest == nil {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+	m := runs[0].Manifest
+	if m.Name != "bundle" || m.Score == nil || *m.Score != 1000 {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+	if filepath.Dir(m.Profiles["cpu"]) != dir {
+		t.Fatalf("unexpected cpu profile path: %s", m.Profiles["cpu"])
+	}
+	reports := map[string]string{}
+	for _, artifact := range m.Artifacts {
+		if artifact.Path == "" {
+			reports[artifact.Name] = artifact.URL
+			continue
+		}
+		if filepath.Dir(artifact.Path) != dir {
+			t.Fatalf("artifact %s is not imported: %s", artifact.Name, artifact.Path)
+		}
+		b, err := os.ReadFile(artifact.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reports[artifact.Name] = string(b)
+	}
+	if reports["alp"] != "| COUNT | METHOD | URI |" || reports["benchmark"] != "pass" || reports["kataribe"] != "https://gist.github.com/kataribe" {
+		t.Fatalf("unexpected artifacts: %+v", reports)
+	}
+	if _, err := dst.ImportRun(bytes.NewReader(bundle.Bytes())); err == nil {
+		t.Fatal("expected error for the existing run")
+	}
+}
+
+func TestProfilerImportInvalidBundle(t *testing.T) {
+	var bundle bytes.Buffer
+	gw := gzip.NewWriter(&bundle)
+	tw := tar.NewWriter(gw)
+	for _, name := range []string{"pprof_2022_01_02_03_04_05.pprof", "../pprof_2022_01_02_03_04_05.pprof"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gw.Close()
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir)
+	if _, err := p.ImportRun(&bundle); err == nil {
+		t.Fatal("expected error for invalid bundle")
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("files of the invalid bundle remain: %v", matches)
+	}
+}
@@ -0,0 +1,415 @@
+package profiler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+const (
+	continuousEndpoint     = "/continuous"
+	segmentPrefix          = "segment_"
+	defaultSegmentDuration = 30 * time.Second
+	defaultSegmentKeep     = 20
+)
+
+// ContinuousOption specifies the length of a CPU profile segment recorded by StartContinuous and the number of the latest segments kept in baseDir.
+// If keep is zero, every segment is kept.
+func ContinuousOption(segment time.Duration, keep int) ProfilerOption {
+	return func(p *Profiler) {
+		p.segmentDuration = segment
+		p.segmentKeep = keep
+	}
+}
+
+func segmentFileName(currentTime string) string {
+	return fmt.Sprintf("%s%s.pprof", segmentPrefix, currentTime)
+}
+
+type continuous struct {
+	stop chan struct{}
+	done chan struct{}
+	err  error
+}
+
+// recordingSegment is the segment being recorded. It is written to a temporary file and renamed when it is finished.
+type recordingSegment struct {
+	file *os.File
+	path string
+}
+
+// StartContinuous starts recording consecutive CPU profile segments into baseDir until StopContinuous is called.
+// Only the latest segments specified by ContinuousOption are kept, and the web UI merges any range of them at /continuous .
+// Start pauses continuous profiling and Stop resumes it.
+// It returns an error wrapping ErrInvalidState unless Profiler is idle.
+func (p *Profiler) StartContinuous() error {
+	if p.segmentDuration < time.Second {
+		return fmt.Errorf("segment duration must be at least 1s: %s", p.segmentDuration)
+	}
+	if err := p.transition("start", StateIdle, StateContinuous); err != nil {
+		return err
+	}
+	if err := p.startContinuous(); err != nil {
+		p.setState(StateIdle)
+		return err
+	}
+	return nil
+}
+
+func (p *Profiler) startContinuous() error {
+	if err := p.createBaseDirIfNotExists(); err != nil {
+		return err
+	}
+	seg, err := p.startSegment()
+	if err != nil {
+		return err
+	}
+	c := &continuous{stop: make(chan struct{}), done: make(chan struct{})}
+	p.stateMu.Lock()
+	p.continuous = c
+	p.stateMu.Unlock()
+	log.Printf("start continuous pprof: report to %s every %s", p.baseDir, p.segmentDuration)
+	go p.recordSegments(c, seg)
+	return nil
+}
+
+// StopContinuous finishes the current segment and stops continuous profiling.
+// If continuous profiling is paused by Start, it is not resumed by Stop.
+// It returns an error wrapping ErrInvalidState unless Profiler is in continuous mode.
+func (p *Profiler) StopContinuous() error {
+	p.stateMu.Lock()
+	if p.state != StateContinuous {
+		defer p.stateMu.Unlock()
+		if p.pausedContinuous {
+			p.pausedContinuous = false
+			return nil
+		}
+		return &StateError{Op: "stop", State: p.state}
+	}
+	p.state = StateStopping
+	p.stateMu.Unlock()
+	err := p.stopContinuous()
+	p.setState(StateIdle)
+	return err
+}
+
+// stopContinuous finishes the current segment. It is called in StateStopping or StateStarting.
+func (p *Profiler) stopContinuous() error {
+	p.stateMu.Lock()
+	c := p.continuous
+	p.stateMu.Unlock()
+	close(c.stop)
+	<-c.done
+	p.stateMu.Lock()
+	p.continuous = nil
+	p.stateMu.Unlock()
+	return c.err
+}
+
+func (p *Profiler) recordSegments(c *continuous, seg *recordingSegment) {
+	defer close(c.done)
+	ticker := time.NewTicker(p.segmentDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			c.err = p.finishSegment(seg)
+			return
+		case <-ticker.C:
+		}
+		if err := p.finishSegment(seg); err != nil {
+			log.Printf("failed to finish cpu profile segment: %+v", err)
+		}
+		next, err := p.startSegment()
+		if err != nil {
+			// retry at the next tick.
+			log.Printf("failed to start cpu profile segment: %+v", err)
+		}
+		seg = next
+	}
+}
+
+func (p *Profiler) startSegment() (*recordingSegment, error) {
+	path := filepath.Join(p.baseDir, segmentFileName(time.Now().Format(fileFormat)))
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file %s: %w", path+".tmp", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to start cpu profile: %w", err)
+	}
+	return &recordingSegment{file: f, path: path}, nil
+}
+
+func (p *Profiler) finishSegment(seg *recordingSegment) error {
+	if seg == nil {
+		return nil
+	}
+	pprof.StopCPUProfile()
+	if err := seg.file.Close(); err != nil {
+		return fmt.Errorf("failed to close file %s: %w", seg.file.Name(), err)
+	}
+	if err := os.Rename(seg.file.Name(), seg.path); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", seg.file.Name(), err)
+	}
+	return p.pruneSegments()
+}
+
+// pruneSegments removes segments except the latest ones specified by ContinuousOption.
+func (p *Profiler) pruneSegments() error {
+	if p.segmentKeep <= 0 {
+		return nil
+	}
+	segments, err := listSegments(p.baseDir)
+	if err != nil {
+		return err
+	}
+	if len(segments) <= p.segmentKeep {
+		return nil
+	}
+	for _, seg := range segments[:len(segments)-p.segmentKeep] {
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove segment %s: %w", seg.path, err)
+		}
+	}
+	return nil
+}
+
+type segment struct {
+	id   string
+	path string
+	time time.Time
+}
+
+// listSegments returns the finished segments in dir from oldest to newest.
+func listSegments(dir string) ([]*segment, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, segmentPrefix+"*.pprof"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find segments: %w", err)
+	}
+	sort.Strings(matches)
+	segments := make([]*segment, 0, len(matches))
+	for _, path := range matches {
+		id := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), segmentPrefix), ".pprof")
+		t, err := time.ParseInLocation(fileFormat, id, time.Local)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, &segment{id: id, path: path, time: t})
+	}
+	return segments, nil
+}
+
+// segmentRange returns the segments from the id from to the id to. from and to can be in any order.
+func segmentRange(segments []*segment, from, to string) ([]*segment, error) {
+	if from > to {
+		from, to = to, from
+	}
+	begin, end := -1, -1
+	for idx, seg := range segments {
+		if seg.id == from {
+			begin = idx
+		}
+		if seg.id == to {
+			end = idx
+		}
+	}
+	if begin < 0 {
+		return nil, fmt.Errorf("failed to find segment %s", from)
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("failed to find segment %s", to)
+	}
+	return segments[begin : end+1], nil
+}
+
+// lastSegments returns the segments overlapping the last duration d.
+func (p *Profiler) lastSegments(segments []*segment, d time.Duration) []*segment {
+	since := time.Now().Add(-d)
+	for idx, seg := range segments {
+		if seg.time.Add(p.segmentDuration).After(since) {
+			return segments[idx:]
+		}
+	}
+	return nil
+}
+
+// segmentsPrefix returns the path of the merged segments from the id from to the id to: /continuous/<from>/<to> .
+func (p *Profiler) segmentsPrefix(from, to string) (string, []*segment, error) {
+	segments, err := listSegments(p.baseDir)
+	if err != nil {
+		return "", nil, err
+	}
+	selected, err := segmentRange(segments, from, to)
+	if err != nil {
+		return "", nil, err
+	}
+	first, last := selected[0], selected[len(selected)-1]
+	return fmt.Sprintf("%s/%s/%s", continuousEndpoint, first.id, last.id), selected, nil
+}
+
+// segmentsUI returns the pprof web UI of the merged segments at prefix. The latest UIs are cached.
+func (p *Profiler) segmentsUI(prefix string, selected []*segment) (http.Handler, error) {
+	return p.segmentUIs.get(prefix, func() (http.Handler, error) {
+		profs := make([]*profile.Profile, 0, len(selected))
+		for _, seg := range selected {
+			prof, err := parseProfileFile(seg.path)
+			if err != nil {
+				return nil, err
+			}
+			profs = append(profs, prof)
+		}
+		merged, err := profile.Merge(profs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge segments: %w", err)
+		}
+		nav := []navLink{{Name: "segments", Path: continuousEndpoint}}
+		h, err := p.profileHandler(prefix, merged, nil, nav)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mount segments: %w", err)
+		}
+		return h, nil
+	})
+}
+
+var continuousTmpl = newPageTemplate("continuous", `<!DOCTYPE html>
+<html>
+<head><title>continuous</title></head>
+<body>
+<h2>continuous</h2>
+{{ if .Error }}<p style="color:red">{{ .Error }}</p>{{ end }}
+<p>
+{{- range .Lasts }}<a href="{{ $.Endpoint }}?last={{ . }}">last {{ . }}</a> {{ end -}}
+</p>
+<form action="{{ .Endpoint }}" method="get">
+  from
+  <select name="from">
+  {{- range .Segments }}<option value="{{ .ID }}">{{ .Time }}</option>{{ end -}}
+  </select>
+  to
+  <select name="to">
+  {{- range .Segments }}<option value="{{ .ID }}">{{ .Time }}</option>{{ end -}}
+  </select>
+  <input type="submit" value="merge">
+</form>
+</body>
+</html>`)
+
+var continuousLasts = []string{"1m", "5m", "15m", "30m"}
+
+type continuousSegment struct {
+	ID   string
+	Time string
+}
+
+// continuousHandler merges the segments selected by the range ( from and to ) or the duration ( last ) and redirects to them.
+// It serves the merged segments at /continuous/<from>/<to>/ as well.
+type continuousHandler struct {
+	profiler *Profiler
+}
+
+func (h *continuousHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, continuousEndpoint+"/") {
+		h.serveSegments(w, r)
+		return
+	}
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if last := query.Get("last"); last != "" {
+		d, err := time.ParseDuration(last)
+		if err != nil {
+			h.render(w, fmt.Errorf("invalid duration %s: %w", last, err))
+			return
+		}
+		segments, err := listSegments(h.profiler.baseDir)
+		if err != nil {
+			h.render(w, err)
+			return
+		}
+		selected := h.profiler.lastSegments(segments, d)
+		if len(selected) == 0 {
+			h.render(w, fmt.Errorf("no segment in the last %s", d))
+			return
+		}
+		from, to = selected[0].id, selected[len(selected)-1].id
+	}
+	if from == "" || to == "" {
+		h.render(w, nil)
+		return
+	}
+	prefix, _, err := h.profiler.segmentsPrefix(from, to)
+	if err != nil {
+		h.render(w, err)
+		return
+	}
+	http.Redirect(w, r, prefix+"/", http.StatusFound)
+}
+
+func (h *continuousHandler) serveSegments(w http.ResponseWriter, r *http.Request) {
+	// the path is /continuous/<from>/<to>/<page of pprof> .
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, continuousEndpoint+"/"), "/", 3)
+	if len(parts) < 3 {
+		http.NotFound(w, r)
+		return
+	}
+	prefix, selected, err := h.profiler.segmentsPrefix(parts[0], parts[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if prefix != fmt.Sprintf("%s/%s/%s", continuousEndpoint, parts[0], parts[1]) {
+		u := *r.URL
+		u.Path = prefix + "/" + parts[2]
+		http.Redirect(w, r, u.RequestURI(), http.StatusFound)
+		return
+	}
+	handler, err := h.profiler.segmentsUI(prefix, selected)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+func (h *continuousHandler) render(w http.ResponseWriter, err error) {
+	segments, listErr := listSegments(h.profiler.baseDir)
+	if err == nil {
+		err = listErr
+	}
+	rows := make([]continuousSegment, 0, len(segments))
+	for i := len(segments) - 1; i >= 0; i-- {
+		rows = append(rows, continuousSegment{
+			ID:   segments[i].id,
+			Time: segments[i].time.Format("2006-01-02 15:04:05"),
+		})
+	}
+	w.Header().Set("Content-Type", "text/html")
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := continuousTmpl.Execute(w, struct {
+		Endpoint string
+		Lasts    []string
+		Segments []continuousSegment
+		Error    string
+	}{
+		Endpoint: continuousEndpoint,
+		Lasts:    continuousLasts,
+		Segments: rows,
+		Error:    errMsg,
+	}); err != nil {
+		log.Printf("failed to render continuous page: %+v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package profiler_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestProfilerContinuous(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.ContinuousOption(time.Second, 2))
+	if err := p.StartContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.StartContinuous(); !errors.Is(err, profilertools.ErrInvalidState) {
+		t.Fatalf("expected invalid state error but got %v", err)
+	}
+	if status := p.Status(); status.State != profilertools.StateContinuous || status.Run != nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	time.Sleep(2500 * time.Millisecond)
+	if err := p.StopContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	if status := p.Status(); status.State != profilertools.StateIdle {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	segments, err := filepath.Glob(filepath.Join(dir, "segment_*.pprof"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected two segments but got %v", segments)
+	}
+	tmps, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmps) != 0 {
+		t.Fatalf("unexpected temporary files: %v", tmps)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProfilerContinuousPause(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.ContinuousOption(time.Second, 0))
+	if err := p.StartContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	// Start pauses continuous profiling and Stop resumes it.
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if status := p.Status(); status.State != profilertools.StateRunning || status.Run == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	segments, err := filepath.Glob(filepath.Join(dir, "segment_*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || filepath.Ext(segments[0]) != ".pprof" {
+		t.Fatalf("the segment is not finished: %v", segments)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if status := p.Status(); status.State != profilertools.StateContinuous {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	// StopContinuous during the run cancels the resume.
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.StopContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if status := p.Status(); status.State != profilertools.StateIdle {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if err := p.StopContinuous(); !errors.Is(err, profilertools.ErrInvalidState) {
+		t.Fatalf("expected invalid state error but got %v", err)
+	}
+}
+
+func TestProfilerContinuousUI(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Add(-time.Minute)
+	ids := make([]string, 12)
+	for idx := range ids {
+		ids[idx] = now.Add(time.Duration(idx) * time.Second).Format("2006_01_02_15_04_05")
+	}
+	writeTestRuns(t, dir, ids...)
+	for _, id := range ids {
+		if err := os.Rename(filepath.Join(dir, "pprof_"+id+".pprof"), filepath.Join(dir, "segment_"+id+".pprof")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := profilertools.NewProfiler(dir)
+	h, err := profilertools.ServeHandler(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	last := ids[len(ids)-1]
+	if rec := get("/continuous?last=5m"); rec.Code != http.StatusFound || rec.Header().Get("Location") != fmt.Sprintf("/continuous/%s/%s/", ids[0], last) {
+		t.Fatalf("unexpected redirect: %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	// every range is served by one handler, and only the latest ones are kept.
+	for _, id := range ids {
+		if rec := get(fmt.Sprintf("/continuous/%s/%s/top", id, last)); rec.Code != http.StatusOK {
+			t.Fatalf("failed to serve segments from %s: %d %s", id, rec.Code, rec.Body.String())
+		}
+	}
+	if cached := profilertools.CachedSegmentUIs(p); cached != 8 {
+		t.Fatalf("unexpected cached UIs: %d", cached)
+	}
+	if rec := get(fmt.Sprintf("/continuous/%s/%s/top?si=cpu", last, ids[0])); rec.Code != http.StatusFound || rec.Header().Get("Location") != fmt.Sprintf("/continuous/%s/%s/top?si=cpu", ids[0], last) {
+		t.Fatalf("unexpected redirect: %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := get("/continuous/unknown/" + last + "/top"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 but got %d", rec.Code)
+	}
+}
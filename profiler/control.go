@@ -0,0 +1,224 @@
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	controlEndpoint = "/debug/profiler"
+)
+
+// ControlRoutesOption registers routes to control Profiler on e:
+// POST /debug/profiler/start, POST /debug/profiler/stop, POST /debug/profiler/continuous/start, POST /debug/profiler/continuous/stop,
+// POST /debug/profiler/score, GET /debug/profiler/status, GET /debug/profiler/runs, GET /debug/profiler/runs/:id/profile ( the CPU profile of the run ),
+// GET /debug/profiler/runs/:id/bundle ( the bundle written by ExportRun ) and POST /debug/profiler/runs/import ( the body is a bundle ).
+// POST /debug/profiler/start accepts StartRequest as an optional body, and GET /debug/profiler/runs filters the runs by ?name=<name>&label=<key>=<value> .
+// Requests are authorized by cred like the web UI. The routes reject every request if cred is empty.
+func ControlRoutesOption(e *echo.Echo, cred Credential) ProfilerOption {
+	return func(p *Profiler) {
+		h := &controlHandler{profiler: p}
+		g := e.Group(controlEndpoint, controlAuthMiddleware(cred))
+		g.POST("/start", h.start)
+		g.POST("/stop", h.stop)
+		g.POST("/continuous/start", h.startContinuous)
+		g.POST("/continuous/stop", h.stopContinuous)
+		g.POST("/score", h.score)
+		g.GET("/status", h.status)
+		g.GET("/runs", h.runs)
+		g.GET("/runs/:id/profile", h.profile)
+		g.GET("/runs/:id/bundle", h.exportRun)
+		g.POST("/runs/import", h.importRun)
+	}
+}
+
+// controlAuthMiddleware doesn't let the routes open without a credential because they control the profiler.
+func controlAuthMiddleware(cred Credential) echo.MiddlewareFunc {
+	if !cred.enabled() {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusUnauthorized, "credential is not configured")
+			}
+		}
+	}
+	return echo.WrapMiddleware(cred.handler)
+}
+
+// RunInfo is a run stored in baseDir.
+type RunInfo struct {
+	ID       string    `json:"id"`
+	Profile  string    `json:"profile"`
+	Manifest *Manifest `json:"manifest,omitempty"`
+}
+
+// Runs returns the runs stored in baseDir from newest to oldest.
+func (p *Profiler) Runs() ([]*RunInfo, error) {
+	return p.FilterRuns(RunFilter{})
+}
+
+// FilterRuns returns the runs matching filter from newest to oldest.
+func (p *Profiler) FilterRuns(filter RunFilter) ([]*RunInfo, error) {
+	if err := p.createBaseDirIfNotExists(); err != nil {
+		return nil, err
+	}
+	stored, err := listStoredRuns(p.baseDir)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*RunInfo, 0, len(stored))
+	for _, r := range stored {
+		pprofPath := filepath.Join(p.baseDir, cpuProfileFileName(r.id))
+		if _, err := os.Stat(pprofPath); err != nil {
+			continue
+		}
+		manifest, err := readManifest(filepath.Join(p.baseDir, manifestFileName(r.id)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if !filter.match(manifest) {
+			continue
+		}
+		runs = append(runs, &RunInfo{ID: r.id, Profile: pprofPath, Manifest: manifest})
+	}
+	return runs, nil
+}
+
+type controlHandler struct {
+	profiler *Profiler
+}
+
+// StartRequest is the optional body of POST /debug/profiler/start .
+type StartRequest struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+}
+
+func (h *controlHandler) start(c echo.Context) error {
+	var req StartRequest
+	if c.Request().ContentLength != 0 {
+		if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("failed to decode request: %s", err))
+		}
+	}
+	if err := h.profiler.StartContext(c.Request().Context(), RunNameOption(req.Name), RunLabelsOption(req.Labels)); err != nil {
+		return controlError(err)
+	}
+	return c.JSON(http.StatusOK, h.profiler.Status())
+}
+
+func (h *controlHandler) stop(c echo.Context) error {
+	if err := h.profiler.StopContext(c.Request().Context()); err != nil {
+		return controlError(err)
+	}
+	return c.JSON(http.StatusOK, h.profiler.Status())
+}
+
+func (h *controlHandler) startContinuous(c echo.Context) error {
+	if err := h.profiler.StartContinuous(); err != nil {
+		return controlError(err)
+	}
+	return c.JSON(http.StatusOK, h.profiler.Status())
+}
+
+func (h *controlHandler) stopContinuous(c echo.Context) error {
+	if err := h.profiler.StopContinuous(); err != nil {
+		return controlError(err)
+	}
+	return c.JSON(http.StatusOK, h.profiler.Status())
+}
+
+// ScoreRequest is the body of POST /debug/profiler/score .
+type ScoreRequest struct {
+	Score  float64 `json:"score"`
+	Output string  `json:"output"`
+}
+
+func (h *controlHandler) score(c echo.Context) error {
+	var req ScoreRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("failed to decode request: %s", err))
+	}
+	if err := h.profiler.SetScore(req.Score, req.Output); err != nil {
+		return controlError(err)
+	}
+	return c.JSON(http.StatusOK, h.profiler.Status())
+}
+
+func (h *controlHandler) status(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.profiler.Status())
+}
+
+func (h *controlHandler) runs(c echo.Context) error {
+	filter, err := ParseRunFilter(c.QueryParam("name"), c.QueryParams()["label"])
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	runs, err := h.profiler.FilterRuns(filter)
+	if err != nil {
+		return controlError(err)
+	}
+	return c.JSON(http.StatusOK, runs)
+}
+
+func (h *controlHandler) profile(c echo.Context) error {
+	runs, err := h.profiler.Runs()
+	if err != nil {
+		return controlError(err)
+	}
+	for _, run := range runs {
+		if run.ID == c.Param("id") {
+			return c.File(run.Profile)
+		}
+	}
+	return echo.NewHTTPError(http.StatusNotFound, "run not found")
+}
+
+func (h *controlHandler) exportRun(c echo.Context) error {
+	runs, err := h.profiler.Runs()
+	if err != nil {
+		return controlError(err)
+	}
+	for _, run := range runs {
+		if run.ID != c.Param("id") {
+			continue
+		}
+		c.Response().Header().Set(echo.HeaderContentType, "application/gzip")
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", bundleFileName(run.ID)))
+		if err := h.profiler.ExportRun(run.ID, c.Response()); err != nil {
+			return controlError(err)
+		}
+		return nil
+	}
+	return echo.NewHTTPError(http.StatusNotFound, "run not found")
+}
+
+func (h *controlHandler) importRun(c echo.Context) error {
+	id, err := h.profiler.ImportRun(c.Request().Body)
+	if err != nil {
+		return controlError(err)
+	}
+	runs, err := h.profiler.Runs()
+	if err != nil {
+		return controlError(err)
+	}
+	for _, run := range runs {
+		if run.ID == id {
+			return c.JSON(http.StatusOK, run)
+		}
+	}
+	return echo.NewHTTPError(http.StatusNotFound, "run not found")
+}
+
+func controlError(err error) error {
+	if errors.Is(err, ErrInvalidState) {
+		return echo.NewHTTPError(http.StatusConflict, err.Error()).SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error()).SetInternal(err)
+}
@@ -0,0 +1,107 @@
+package profiler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+	"github.com/labstack/echo/v4"
+)
+
+func TestControlRoutes(t *testing.T) {
+	e := echo.New()
+	profilertools.NewProfiler(t.TempDir(), profilertools.ControlRoutesOption(e, profilertools.TokenCredential("secret")))
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		var body io.Reader
+		if method == http.MethodPost && strings.HasSuffix(path, "/start") {
+			body = strings.NewReader(`{"name":"control","labels":{"branch":"main"}}`)
+		}
+		req := httptest.NewRequest(method, path, body)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, tc := range []struct {
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{method: http.MethodGet, path: "/debug/profiler/status", status: http.StatusUnauthorized},
+		{method: http.MethodPost, path: "/debug/profiler/start", token: "invalid", status: http.StatusUnauthorized},
+		{method: http.MethodPost, path: "/debug/profiler/stop", token: "secret", status: http.StatusConflict},
+		{method: http.MethodPost, path: "/debug/profiler/start", token: "secret", status: http.StatusOK},
+		{method: http.MethodPost, path: "/debug/profiler/start", token: "secret", status: http.StatusConflict},
+		{method: http.MethodPost, path: "/debug/profiler/stop", token: "secret", status: http.StatusOK},
+	} {
+		if rec := request(tc.method, tc.path, tc.token); rec.Code != tc.status {
+			t.Fatalf("%s %s: expected %d but got %d: %s", tc.method, tc.path, tc.status, rec.Code, rec.Body.String())
+		}
+	}
+	rec := request(http.MethodGet, "/debug/profiler/status", "secret")
+	var status profilertools.Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.State != profilertools.StateIdle {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	rec = request(http.MethodGet, "/debug/profiler/runs", "secret")
+	var runs []*profilertools.RunInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Manifest == nil || runs[0].Manifest.Name != "control" {
+		t.Fatalf("unexpected runs: %s", rec.Body.String())
+	}
+	rec = request(http.MethodGet, "/debug/profiler/runs?name=control&label=branch=dev", "secret")
+	var filtered []*profilertools.RunInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &filtered); err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 0 {
+		t.Fatalf("unexpected filtered runs: %s", rec.Body.String())
+	}
+	if rec := request(http.MethodGet, "/debug/profiler/runs/"+runs[0].ID+"/profile", "secret"); rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatalf("failed to download profile: %d", rec.Code)
+	}
+	if rec := request(http.MethodGet, "/debug/profiler/runs/"+runs[0].ID+"/bundle", "secret"); rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "application/gzip" {
+		t.Fatalf("failed to download bundle: %d", rec.Code)
+	}
+	if rec := request(http.MethodPost, "/debug/profiler/runs/import", "secret"); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected error for empty bundle but got %d", rec.Code)
+	}
+	if rec := request(http.MethodGet, "/debug/profiler/runs/unknown/profile", "secret"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 but got %d", rec.Code)
+	}
+}
+
+func TestControlRoutesAuth(t *testing.T) {
+	basic := echo.New()
+	profilertools.NewProfiler(t.TempDir(), profilertools.ControlRoutesOption(basic, profilertools.BasicAuthCredential("isucon", "pass")))
+	empty := echo.New()
+	profilertools.NewProfiler(t.TempDir(), profilertools.ControlRoutesOption(empty, profilertools.Credential{}))
+	serve := func(e *echo.Echo, req *http.Request) int {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	req := httptest.NewRequest(http.MethodGet, "/debug/profiler/status", nil)
+	if status := serve(basic, req); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 but got %d", status)
+	}
+	req.SetBasicAuth("isucon", "pass")
+	if status := serve(basic, req); status != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", status)
+	}
+	if status := serve(empty, httptest.NewRequest(http.MethodGet, "/debug/profiler/status", nil)); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credential but got %d", status)
+	}
+}
@@ -0,0 +1,184 @@
+package profiler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	diffEndpoint = "/diff"
+)
+
+var errRunNotFound = errors.New("run not found")
+
+var diffTmpl = newPageTemplate("diff", `<!DOCTYPE html>
+<html>
+<head><title>diff</title></head>
+<body>
+<h2>diff</h2>
+{{ if .Error }}<p style="color:red">{{ .Error }}</p>{{ end }}
+<form action="{{ .Endpoint }}" method="get">
+  base
+  <select name="base">
+  {{- range .Runs }}<option value="{{ .Idx }}"{{ if eq .Idx $.Base }} selected{{ end }}>{{ .Idx }}: {{ .Name }}</option>{{ end -}}
+  </select>
+  target
+  <select name="target">
+  {{- range .Runs }}<option value="{{ .Idx }}"{{ if eq .Idx $.Target }} selected{{ end }}>{{ .Idx }}: {{ .Name }}</option>{{ end -}}
+  </select>
+  <input type="submit" value="compare">
+</form>
+</body>
+</html>`)
+
+type diffRun struct {
+	Idx  int
+	Name string
+}
+
+// diffHandler redirects to the pprof web UI of target compared with base at /diff/<base>/<target>/ and serves it.
+type diffHandler struct {
+	profiler *Profiler
+}
+
+func (h *diffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, diffEndpoint+"/") {
+		h.serveDiff(w, r)
+		return
+	}
+	query := r.URL.Query()
+	base, baseErr := strconv.Atoi(query.Get("base"))
+	target, targetErr := strconv.Atoi(query.Get("target"))
+	if baseErr == nil && targetErr == nil {
+		if _, _, err := h.profiler.diffRuns(base, target); err != nil {
+			h.render(w, base, target, err)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("%s/%d/%d/", diffEndpoint, base, target), http.StatusFound)
+		return
+	}
+	if targetErr != nil {
+		target = h.profiler.lastRunIdx()
+	}
+	if baseErr != nil {
+		base = target - 1
+	}
+	h.render(w, base, target, nil)
+}
+
+func (h *diffHandler) render(w http.ResponseWriter, base, target int, err error) {
+	runs := []diffRun{}
+	for _, r := range h.profiler.loadedRuns() {
+		runs = append(runs, diffRun{Idx: r.idx, Name: r.name})
+	}
+	w.Header().Set("Content-Type", "text/html")
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+		if errors.Is(err, errRunNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+	if err := diffTmpl.Execute(w, struct {
+		Endpoint string
+		Runs     []diffRun
+		Base     int
+		Target   int
+		Error    string
+	}{
+		Endpoint: diffEndpoint,
+		Runs:     runs,
+		Base:     base,
+		Target:   target,
+		Error:    errMsg,
+	}); err != nil {
+		log.Printf("failed to render diff page: %+v", err)
+	}
+}
+
+func (h *diffHandler) serveDiff(w http.ResponseWriter, r *http.Request) {
+	// the path is /diff/<base>/<target>/<page of pprof> .
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, diffEndpoint+"/"), "/", 3)
+	if len(parts) < 3 {
+		http.NotFound(w, r)
+		return
+	}
+	base, baseErr := strconv.Atoi(parts[0])
+	target, targetErr := strconv.Atoi(parts[1])
+	if baseErr != nil || targetErr != nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler, err := h.profiler.diffUI(base, target)
+	if errors.Is(err, errRunNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+func (p *Profiler) diffRuns(base, target int) (*run, *run, error) {
+	baseRun := p.findRun(base)
+	if baseRun == nil {
+		return nil, nil, fmt.Errorf("failed to find run %d: %w", base, errRunNotFound)
+	}
+	targetRun := p.findRun(target)
+	if targetRun == nil {
+		return nil, nil, fmt.Errorf("failed to find run %d: %w", target, errRunNotFound)
+	}
+	return baseRun, targetRun, nil
+}
+
+// diffUI returns the pprof web UI of target compared with base. The latest UIs are cached.
+func (p *Profiler) diffUI(base, target int) (http.Handler, error) {
+	baseRun, targetRun, err := p.diffRuns(base, target)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("%s/%d/%d", diffEndpoint, base, target)
+	return p.diffUIs.get(prefix, func() (http.Handler, error) {
+		nav := []navLink{
+			{Name: fmt.Sprintf("base: %s", baseRun.name), Path: fmt.Sprintf("/%d/", base)},
+			{Name: fmt.Sprintf("target: %s", targetRun.name), Path: fmt.Sprintf("/%d/", target)},
+			{Name: "compare other runs", Path: diffEndpoint},
+		}
+		h, err := p.profileHandler(prefix, targetRun.cpu, baseRun.cpu, nav)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mount diff: %w", err)
+		}
+		return h, nil
+	})
+}
+
+func (p *Profiler) loadedRuns() []*run {
+	p.runsMu.RLock()
+	defer p.runsMu.RUnlock()
+	return append([]*run{}, p.runs...)
+}
+
+func (p *Profiler) findRun(idx int) *run {
+	for _, r := range p.loadedRuns() {
+		if r.idx == idx {
+			return r
+		}
+	}
+	return nil
+}
+
+func (p *Profiler) lastRunIdx() int {
+	runs := p.loadedRuns()
+	if len(runs) == 0 {
+		return 0
+	}
+	return runs[len(runs)-1].idx
+}
@@ -0,0 +1,47 @@
+package profiler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestProfilerDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRuns(t, dir, "2020_01_01_00_00_01", "2020_01_01_00_00_02", "2020_01_01_00_00_03")
+	p := profilertools.NewProfiler(dir)
+	h, err := profilertools.ServeHandler(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := get("/diff"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `value="compare"`) {
+		t.Fatalf("unexpected diff page: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := get("/diff?base=0&target=1"); rec.Code != http.StatusFound || rec.Header().Get("Location") != "/diff/0/1/" {
+		t.Fatalf("unexpected redirect: %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	for _, pair := range [][2]int{{0, 1}, {1, 2}, {0, 2}, {0, 1}} {
+		rec := get(fmt.Sprintf("/diff/%d/%d/top", pair[0], pair[1]))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "compare other runs") {
+			t.Fatalf("unexpected diff %v: %d %s", pair, rec.Code, rec.Body.String())
+		}
+	}
+	if cached := profilertools.CachedDiffUIs(p); cached != 3 {
+		t.Fatalf("unexpected cached UIs: %d", cached)
+	}
+	if rec := get("/diff?base=0&target=99"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 but got %d", rec.Code)
+	}
+	if rec := get("/diff/0/99/top"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 but got %d", rec.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package profiler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/google/pprof/driver"
+	"github.com/google/pprof/profile"
+)
+
+// ExportFormat is the format of a static file exported from the CPU profile of a run.
+type ExportFormat string
+
+const (
+	// FlamegraphHTML is the flame graph of the pprof web UI as a self-contained HTML file.
+	FlamegraphHTML ExportFormat = "flamegraph"
+	// CallGraphSVG is the call graph rendered by graphviz ( dot command is required ).
+	CallGraphSVG ExportFormat = "callgraph"
+)
+
+// ExportOption exports the CPU profile of every run to static files in baseDir on Stop
+// to share it with those who cannot access the web UI. The files are recorded as artifacts of the run.
+func ExportOption(formats ...ExportFormat) ProfilerOption {
+	return func(p *Profiler) {
+		p.exportFormats = append(p.exportFormats, formats...)
+	}
+}
+
+func exportFileName(format ExportFormat, currentTime string) string {
+	switch format {
+	case CallGraphSVG:
+		return fmt.Sprintf("%s_%s.svg", format, currentTime)
+	}
+	return fmt.Sprintf("%s_%s.html", format, currentTime)
+}
+
+// exportProfile writes prof in every format specified by ExportOption.
+func (p *Profiler) exportProfile(prof *profile.Profile) ([]Artifact, error) {
+	var (
+		artifacts []Artifact
+		errs      MultiError
+	)
+	for _, format := range p.exportFormats {
+		path := filepath.Join(p.baseDir, exportFileName(format, p.currentTime))
+		var err error
+		switch format {
+		case FlamegraphHTML:
+			err = exportFlamegraph(prof, path)
+		case CallGraphSVG:
+			err = exportCallGraph(prof, path)
+		default:
+			err = fmt.Errorf("unknown export format %s", format)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		artifacts = append(artifacts, Artifact{Name: string(format), Path: path})
+	}
+	return artifacts, errs.errorOrNil()
+}
+
+// exportFlamegraph renders the flame graph page of the pprof web UI. The scripts and styles are embedded in the page.
+func exportFlamegraph(prof *profile.Profile, path string) error {
+	var handler http.Handler
+	if err := driver.PProf(&driver.Options{
+		Fetch:   &fetcher{pprof: prof},
+		UI:      new(ui),
+		Flagset: &flagSet{},
+		HTTPServer: func(args *driver.HTTPServerArgs) error {
+			handler = args.Handlers["/flamegraph"]
+			return nil
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to run pprof: %w", err)
+	}
+	if handler == nil {
+		return fmt.Errorf("failed to find flamegraph handler")
+	}
+	req, err := http.NewRequest(http.MethodGet, "/flamegraph", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	w := &bufferedResponseWriter{header: http.Header{}, status: http.StatusOK}
+	handler.ServeHTTP(w, req)
+	if w.status != http.StatusOK {
+		return fmt.Errorf("failed to render flamegraph: %s", w.buf.String())
+	}
+	if err := os.WriteFile(path, w.buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return nil
+}
+
+// exportCallGraph runs pprof like `pprof -svg -output <path>` .
+func exportCallGraph(prof *profile.Profile, path string) error {
+	if err := driver.PProf(&driver.Options{
+		Fetch:   &fetcher{pprof: prof},
+		UI:      new(ui),
+		Flagset: &exportFlagSet{format: string(CallGraphSVG), output: path},
+	}); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to export call graph: %w", err)
+	}
+	return nil
+}
+
+// exportFlagSet is the flag set of the pprof command line which writes a report in format to output.
+// Unlike flagSet, it keeps the default values of the other flags.
+type exportFlagSet struct {
+	format string
+	output string
+}
+
+func (s *exportFlagSet) Bool(name string, def bool, usage string) *bool {
+	v := def
+	if name == "svg" && s.format == string(CallGraphSVG) {
+		v = true
+	}
+	return &v
+}
+func (s *exportFlagSet) Int(name string, def int, usage string) *int {
+	return &def
+}
+func (s *exportFlagSet) Float64(name string, def float64, usage string) *float64 {
+	return &def
+}
+func (s *exportFlagSet) String(name string, def string, usage string) *string {
+	if name == "output" {
+		return &s.output
+	}
+	return &def
+}
+func (s *exportFlagSet) StringList(name string, def string, usage string) *[]*string {
+	var v []*string
+	return &v
+}
+func (s *exportFlagSet) ExtraUsage() string {
+	return ""
+}
+
+func (s *exportFlagSet) AddExtraUsage(eu string) {
+}
+
+func (s *exportFlagSet) Parse(usage func()) []string {
+	return []string{profileSource}
+}
@@ -0,0 +1,51 @@
+package profiler_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestProfilerExport(t *testing.T) {
+	formats := []profilertools.ExportFormat{profilertools.FlamegraphHTML}
+	patterns := []string{"flamegraph_*.html"}
+	if _, err := exec.LookPath("dot"); err == nil {
+		formats = append(formats, profilertools.CallGraphSVG)
+		patterns = append(patterns, "callgraph_*.svg")
+	}
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.ExportOption(formats...))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 {
+			t.Fatalf("expected one %s but got %v", pattern, matches)
+		}
+		b, err := os.ReadFile(matches[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "<svg") && !strings.Contains(string(b), "flamegraph") {
+			t.Fatalf("unexpected content of %s", matches[0])
+		}
+	}
+	runs, err := p.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || len(runs[0].Manifest.Artifacts) != len(formats) {
+		t.Fatalf("exported files are not recorded: %+v", runs[0].Manifest)
+	}
+}
@@ -0,0 +1,250 @@
+package profiler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	artifactEndpoint = "/artifacts/"
+	indexTopN        = 5
+)
+
+var indexTmpl = newPageTemplate("index", `<!DOCTYPE html>
+<html>
+<head>
+<title>profiler</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+ul { margin: 0; padding-left: 16px; }
+</style>
+</head>
+<body>
+<h2>runs</h2>
+<p><a href="{{ .DiffEndpoint }}">diff</a> <a href="{{ .ContinuousEndpoint }}">continuous</a> <a href="{{ .ScoreEndpoint }}">scores</a> <a href="{{ .TrendEndpoint }}">trends</a></p>
+<form method="get" action="/">
+name <input type="text" name="name" value="{{ .Filter.Name }}">
+label <input type="text" name="label" value="{{ .Label }}" placeholder="key=value">
+<input type="submit" value="filter">
+</form>
+<table>
+<tr><th>#</th><th>name</th><th>file</th><th>time</th><th>duration</th><th>samples</th><th>context</th><th>top functions</th><th>views</th><th>artifacts</th></tr>
+{{- range $run := .Runs }}
+<tr>
+  <td>{{ .Idx }}</td>
+  <td>{{ .RunName }}</td>
+  <td><a href="/{{ .Idx }}/">{{ .Name }}</a></td>
+  <td>{{ .Time }}</td>
+  <td>{{ .Duration }}</td>
+  <td>{{ .Samples }}</td>
+  <td><ul>{{ range .Context }}<li>{{ . }}</li>{{ end }}</ul></td>
+  <td><ul>{{ range .TopFunctions }}<li>{{ printf "%.1f" .Percent }}% {{ .Name }}</li>{{ end }}</ul></td>
+  <td>{{ range .Links }}<a href="{{ .Path }}">{{ .Name }}</a> {{ end }}</td>
+  <td><ul>{{ range .Artifacts }}<li>
+    {{- if .Local }}<a href="/{{ $run.Idx }}/artifacts/{{ .Name }}">{{ .Name }}</a>{{ else }}{{ .Name }}{{ end }}
+    {{- if .URL }} (<a href="{{ .URL }}">gist</a>){{ end -}}
+  </li>{{ end }}</ul></td>
+</tr>
+{{- end }}
+</table>
+</body>
+</html>`)
+
+type indexFunction struct {
+	Name    string
+	Percent float64
+}
+
+type indexArtifact struct {
+	Name  string
+	URL   string
+	Local bool
+}
+
+type indexRun struct {
+	Idx          int
+	Name         string
+	RunName      string
+	Time         string
+	Duration     time.Duration
+	Samples      int
+	TopFunctions []indexFunction
+	Links        []navLink
+	Artifacts    []indexArtifact
+	Context      []string
+}
+
+// indexHandler lists every loaded run.
+type indexHandler struct {
+	profiler *Profiler
+}
+
+func (h *indexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		h.profiler.traceViewer.ServeHTTP(w, r)
+		return
+	}
+	query := r.URL.Query()
+	filter, err := ParseRunFilter(query.Get("name"), query["label"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	runs := h.profiler.loadedRuns()
+	// runs imported or found by the watcher later may be older than the others, so they are listed by time.
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].time.After(runs[j].time)
+	})
+	indexRuns := make([]indexRun, 0, len(runs))
+	h.profiler.runsMu.RLock()
+	for _, run := range runs {
+		if !filter.match(run.manifest) {
+			continue
+		}
+		indexRuns = append(indexRuns, newIndexRun(run))
+	}
+	h.profiler.runsMu.RUnlock()
+	w.Header().Set("Content-Type", "text/html")
+	if err := indexTmpl.Execute(w, struct {
+		DiffEndpoint       string
+		ContinuousEndpoint string
+		ScoreEndpoint      string
+		TrendEndpoint      string
+		Filter             RunFilter
+		Label              string
+		Runs               []indexRun
+	}{
+		DiffEndpoint:       diffEndpoint,
+		ContinuousEndpoint: continuousEndpoint,
+		ScoreEndpoint:      scoreEndpoint,
+		TrendEndpoint:      trendEndpoint,
+		Filter:             filter,
+		Label:              query.Get("label"),
+		Runs:               indexRuns,
+	}); err != nil {
+		log.Printf("failed to render index page: %+v", err)
+	}
+}
+
+func newIndexRun(r *run) indexRun {
+	stats, total := topFunctions(r.cpu)
+	if len(stats) > indexTopN {
+		stats = stats[:indexTopN]
+	}
+	topFuncs := make([]indexFunction, 0, len(stats))
+	for _, stat := range stats {
+		var percent float64
+		if total != 0 {
+			percent = float64(stat.Flat) / float64(total) * 100
+		}
+		topFuncs = append(topFuncs, indexFunction{Name: stat.Name, Percent: percent})
+	}
+	var (
+		artifacts []indexArtifact
+		context   []string
+		runName   string
+	)
+	if m := r.manifest; m != nil {
+		runName = m.Name
+		for _, artifact := range m.Artifacts {
+			_, err := os.Stat(artifact.Path)
+			artifacts = append(artifacts, indexArtifact{
+				Name:  artifact.Name,
+				URL:   artifact.URL,
+				Local: artifact.Path != "" && err == nil,
+			})
+		}
+		context = manifestContext(m)
+	}
+	var runTime string
+	if !r.time.IsZero() {
+		runTime = r.time.Format("2006-01-02 15:04:05")
+	}
+	return indexRun{
+		Idx:          r.idx,
+		Name:         r.name,
+		RunName:      runName,
+		Time:         runTime,
+		Duration:     time.Duration(r.cpu.DurationNanos).Round(time.Millisecond),
+		Samples:      len(r.cpu.Sample),
+		TopFunctions: topFuncs,
+		Links:        r.links,
+		Artifacts:    artifacts,
+		Context:      context,
+	}
+}
+
+func manifestContext(m *Manifest) []string {
+	var ret []string
+	if m.Score != nil {
+		ret = append(ret, fmt.Sprintf("score: %v", *m.Score))
+	}
+	if m.GitCommit != "" {
+		commit := m.GitCommit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		if m.GitDirty {
+			commit += " (dirty)"
+		}
+		ret = append(ret, fmt.Sprintf("commit: %s", commit))
+	}
+	if m.Hostname != "" {
+		ret = append(ret, fmt.Sprintf("host: %s", m.Hostname))
+	}
+	ret = append(ret, fmt.Sprintf("go: %s", m.GoVersion))
+	keys := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ret = append(ret, fmt.Sprintf("%s=%s", k, m.Labels[k]))
+	}
+	return ret
+}
+
+// artifactHandler serves the reports of a run that exist on this host.
+type artifactHandler struct {
+	profiler *Profiler
+	idx      int
+}
+
+func (h *artifactHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	run := h.profiler.findRun(h.idx)
+	if run == nil {
+		http.NotFound(w, r)
+		return
+	}
+	var artifacts []Artifact
+	h.profiler.runsMu.RLock()
+	if run.manifest != nil {
+		artifacts = run.manifest.Artifacts
+	}
+	h.profiler.runsMu.RUnlock()
+	for _, artifact := range artifacts {
+		if artifact.Name != r.URL.Path || artifact.Path == "" {
+			continue
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeFile(w, r, artifact.Path)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (p *Profiler) setRunManifest(name string, manifest *Manifest) {
+	p.runsMu.Lock()
+	defer p.runsMu.Unlock()
+	for _, r := range p.runs {
+		if r.name == name {
+			r.manifest = manifest
+		}
+	}
+}
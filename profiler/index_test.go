@@ -0,0 +1,54 @@
+package profiler_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestProfilerIndex(t *testing.T) {
+	dir := t.TempDir()
+	ids := []string{"2020_01_01_00_00_01", "2020_01_01_00_00_02", "2020_01_01_00_00_03"}
+	writeTestRuns(t, dir, ids...)
+	p := profilertools.NewProfiler(dir)
+	h, err := profilertools.ServeHandler(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the imported run is older than the loaded runs but mounted last.
+	src := t.TempDir()
+	writeTrendRun(t, src, "2020_01_01_00_00_00", 0.1)
+	var bundle bytes.Buffer
+	if err := profilertools.NewProfiler(src).ExportRun("2020_01_01_00_00_00", &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ImportRun(&bundle); err != nil {
+		t.Fatal(err)
+	}
+	ids = append([]string{"2020_01_01_00_00_00"}, ids...)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	body := rec.Body.String()
+	// the runs are listed from newest to oldest.
+	prev := -1
+	for i := len(ids) - 1; i >= 0; i-- {
+		pos := strings.Index(body, "pprof_"+ids[i]+".pprof")
+		if pos < 0 {
+			t.Fatalf("run %s is not listed:\n%s", ids[i], body)
+		}
+		if pos < prev {
+			t.Fatalf("run %s is listed before a newer run:\n%s", ids[i], body)
+		}
+		prev = pos
+	}
+	if !strings.Contains(body, `<a href="/2/">`) {
+		t.Fatalf("the newest run doesn't link to its profile:\n%s", body)
+	}
+}
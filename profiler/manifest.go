@@ -0,0 +1,121 @@
+package profiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Manifest is the metadata of a run stored as manifest_<time>.json next to the CPU profile.
+type Manifest struct {
+	Name      string            `json:"name,omitempty"`
+	StartTime time.Time         `json:"startTime"`
+	EndTime   time.Time         `json:"endTime"`
+	GitCommit string            `json:"gitCommit,omitempty"`
+	GitDirty  bool              `json:"gitDirty"`
+	Hostname  string            `json:"hostname,omitempty"`
+	GoVersion string            `json:"goVersion"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Score     *float64          `json:"score,omitempty"`
+	Profiles  map[string]string `json:"profiles"`
+	Artifacts []Artifact        `json:"artifacts,omitempty"`
+}
+
+// LabelsOption specifies labels recorded in the manifest of every run.
+func LabelsOption(labels map[string]string) ProfilerOption {
+	return func(p *Profiler) {
+		if p.labels == nil {
+			p.labels = map[string]string{}
+		}
+		for k, v := range labels {
+			p.labels[k] = v
+		}
+	}
+}
+
+func manifestFileName(currentTime string) string {
+	return fmt.Sprintf("manifest_%s.json", currentTime)
+}
+
+func (p *Profiler) newManifest(startTime time.Time) *Manifest {
+	hostname, _ := os.Hostname()
+	commit, dirty := gitStatus()
+	labels := make(map[string]string, len(p.labels))
+	for k, v := range p.labels {
+		labels[k] = v
+	}
+	return &Manifest{
+		StartTime: startTime,
+		GitCommit: commit,
+		GitDirty:  dirty,
+		Hostname:  hostname,
+		GoVersion: runtime.Version(),
+		Labels:    labels,
+		Profiles:  map[string]string{},
+	}
+}
+
+// gitStatus returns the commit and dirty state of the git working tree of the current directory.
+func gitStatus() (string, bool) {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	commit := strings.TrimSpace(string(out))
+	status, err := exec.Command("git", "status", "--porcelain").Output()
+	if err != nil {
+		return commit, false
+	}
+	return commit, len(strings.TrimSpace(string(status))) != 0
+}
+
+// collectProfiles records the files written for the run whose CPU profile is pprofPath.
+func (m *Manifest) collectProfiles(pprofPath, currentTime string) {
+	dir := filepath.Dir(pprofPath)
+	m.Profiles["cpu"] = pprofPath
+	for _, typ := range runtimeProfileTypes {
+		path := filepath.Join(dir, profileFileName(typ, currentTime))
+		if _, err := os.Stat(path); err == nil {
+			m.Profiles[string(typ)] = path
+		}
+	}
+	tracePath := filepath.Join(dir, traceFileName(currentTime))
+	if _, err := os.Stat(tracePath); err == nil {
+		m.Profiles["trace"] = tracePath
+	}
+	routePath := filepath.Join(dir, routeReportFileName(currentTime))
+	if _, err := os.Stat(routePath); err == nil {
+		m.Profiles["routes"] = routePath
+	}
+	for host, path := range findRemoteProfiles(dir, currentTime) {
+		m.Profiles["remote:"+host] = path
+	}
+}
+
+func (m *Manifest) write(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+func readManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
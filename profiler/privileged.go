@@ -0,0 +1,34 @@
+package profiler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// readPrivilegedFile calls fn with the output of cmdTmpl formatted with fileName such as `sudo cat %s` ,
+// so files readable by root only such as the access log and the slow query log can be analyzed without copying them.
+// The error of fn is returned as is.
+func readPrivilegedFile(cmdTmpl, fileName string, fn func(io.Reader) error) error {
+	cmd := exec.Command("sh", "-c", fmt.Sprintf(cmdTmpl, fileName))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	if err := fn(stdout); err != nil {
+		// drain the rest to let the command exit.
+		io.Copy(io.Discard, stdout)
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to read %s: %s: %w", fileName, stderr.String(), err)
+	}
+	return nil
+}
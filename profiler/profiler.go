@@ -1,6 +1,7 @@
 package profiler
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"runtime/pprof"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/pprof/driver"
@@ -21,32 +23,112 @@ type SubProfiler interface {
 	Stop() error
 }
 
+// Artifact is a report produced by a SubProfiler for a run.
+// Path is the location of the report on the host which created it, and URL is set if it was uploaded.
+// Key is set if the report was stored in Storage.
+type Artifact struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+	URL  string `json:"url,omitempty"`
+	Key  string `json:"key,omitempty"`
+}
+
+// ArtifactReporter is implemented by SubProfilers that produce reports, to link them from the web UI.
+type ArtifactReporter interface {
+	Artifacts() []Artifact
+}
+
 type Profiler struct {
-	baseDir         string
-	mux             *http.ServeMux
-	lastIdx         int
-	served          bool
-	pprofFile       *os.File
-	redirectOnce    sync.Once
-	redirectHandler *redirectHandler
-	subProfilers    []SubProfiler
+	baseDir                  string
+	mux                      *http.ServeMux
+	lastIdx                  int
+	served                   bool
+	pprofFile                *os.File
+	currentTime              string
+	redirectOnce             sync.Once
+	redirectHandler          *redirectHandler
+	subProfilers             []SubProfiler
+	profileTypes             []ProfileType
+	runtimeProfiles          []*runtimeProfile
+	blockProfileRate         int
+	baseBlockProfileRate     int
+	mutexProfileFraction     int
+	prevMutexProfileFraction int
+	traceEnabled             bool
+	traceDelay               time.Duration
+	traceDuration            time.Duration
+	tracer                   *tracer
+	traceViewer              *traceViewer
+	serverMu                 sync.Mutex
+	server                   *http.Server
+	runsMu                   sync.RWMutex
+	runs                     []*run
+	diffUIs                  *uiCache
+	labels                   map[string]string
+	manifest                 *Manifest
+	retention                *RetentionPolicy
+	watchInterval            time.Duration
+	watchManifestTimeout     time.Duration
+	mountMu                  sync.Mutex
+	loaded                   map[string]struct{}
+	stateMu                  sync.Mutex
+	state                    State
+	segmentDuration          time.Duration
+	segmentKeep              int
+	continuous               *continuous
+	pausedContinuous         bool
+	segmentUIs               *uiCache
+	summary                  *summaryConfig
+	exportFormats            []ExportFormat
+	storage                  Storage
+	auth                     Credential
 }
 
+type run struct {
+	idx      int
+	id       string
+	name     string
+	time     time.Time
+	cpu      *profile.Profile
+	links    []navLink
+	manifest *Manifest
+}
+
+type ProfilerOption func(*Profiler)
+
 type redirectHandler struct {
-	lastIdx int
+	lastIdx int64
 }
 
 func (h *redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	redirectURL := fmt.Sprintf("http://%s/%d%s", r.Host, h.lastIdx, r.URL.Path)
+	redirectURL := fmt.Sprintf("http://%s/%d%s", r.Host, atomic.LoadInt64(&h.lastIdx), r.URL.Path)
 	http.Redirect(w, r, redirectURL, http.StatusFound) // prevent browser cache
 }
 
-func NewProfiler(baseDir string) *Profiler {
-	return &Profiler{
-		baseDir:         baseDir,
-		mux:             http.NewServeMux(),
-		redirectHandler: &redirectHandler{},
+func NewProfiler(baseDir string, opts ...ProfilerOption) *Profiler {
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+	p := &Profiler{
+		baseDir:              baseDir,
+		mux:                  http.NewServeMux(),
+		redirectHandler:      &redirectHandler{},
+		blockProfileRate:     defaultBlockProfileRate,
+		mutexProfileFraction: defaultMutexProfileFraction,
+		traceViewer:          &traceViewer{},
+		diffUIs:              newUICache(defaultUICacheSize),
+		watchInterval:        defaultWatchInterval,
+		watchManifestTimeout: defaultWatchManifestTimeout,
+		loaded:               map[string]struct{}{},
+		state:                StateIdle,
+		segmentDuration:      defaultSegmentDuration,
+		segmentKeep:          defaultSegmentKeep,
+		segmentUIs:           newUICache(defaultUICacheSize),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
 func (p *Profiler) AddProfiler(profiler SubProfiler) {
@@ -54,64 +136,261 @@ func (p *Profiler) AddProfiler(profiler SubProfiler) {
 }
 
 func (p *Profiler) createBaseDirIfNotExists() error {
-	if p.baseDir == "" {
-		p.baseDir = os.TempDir()
-	} else {
-		if err := os.MkdirAll(p.baseDir, 0o755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", p.baseDir, err)
-		}
+	if err := os.MkdirAll(p.baseDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", p.baseDir, err)
 	}
 	return nil
 }
 
+// ListenAndServe serves the web UI. It returns http.ErrServerClosed after Shutdown.
 func (p *Profiler) ListenAndServe(port uint16) error {
+	if err := p.setupServer(); err != nil {
+		return err
+	}
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: p.auth.handler(p.mux)}
+	p.serverMu.Lock()
+	p.server = server
+	p.serverMu.Unlock()
+	return server.ListenAndServe()
+}
+
+// Shutdown stops the web UI served by ListenAndServe and `go tool trace` started by the trace viewer.
+func (p *Profiler) Shutdown(ctx context.Context) error {
+	p.serverMu.Lock()
+	server := p.server
+	p.serverMu.Unlock()
+	defer p.traceViewer.stop()
+	if server == nil {
+		return nil
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown web UI: %w", err)
+	}
+	return nil
+}
+
+// setupServer loads the runs in baseDir and registers the handlers of the web UI.
+func (p *Profiler) setupServer() error {
 	if err := p.createBaseDirIfNotExists(); err != nil {
 		return err
 	}
+	if err := p.PullRuns(context.Background()); err != nil {
+		return fmt.Errorf("failed to pull runs: %w", err)
+	}
+	if err := p.applyRetention(0); err != nil {
+		return err
+	}
+	for _, path := range findCPUProfiles(p.baseDir) {
+		if err := p.addProfileResult(path, nil); err != nil {
+			return fmt.Errorf("failed to add profile result: %w", err)
+		}
+	}
+	if p.watchInterval > 0 {
+		go p.watch(p.watchInterval)
+	}
+	p.mountMu.Lock()
+	p.mux.Handle("/", &indexHandler{profiler: p})
+	p.mux.Handle(diffEndpoint, &diffHandler{profiler: p})
+	p.mux.Handle(diffEndpoint+"/", &diffHandler{profiler: p})
+	p.mux.Handle(continuousEndpoint, &continuousHandler{profiler: p})
+	p.mux.Handle(continuousEndpoint+"/", &continuousHandler{profiler: p})
+	p.mux.Handle(scoreEndpoint, &scoreHandler{profiler: p})
+	p.mux.Handle(trendEndpoint, &trendHandler{profiler: p})
+	p.mux.Handle(traceViewerEndpoint+"/", http.StripPrefix(traceViewerEndpoint, p.traceViewer))
+	p.served = true
+	p.mountMu.Unlock()
+	return nil
+}
+
+func findCPUProfiles(dir string) []string {
 	filePath := []string{}
-	filepath.Walk(p.baseDir, func(path string, info fs.FileInfo, err error) error {
-		if info.IsDir() {
+	filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
 			return nil
 		}
-		if filepath.Ext(path) != ".pprof" {
+		if filepath.Ext(path) != ".pprof" || !strings.HasPrefix(filepath.Base(path), cpuProfilePrefix) {
 			return nil
 		}
 		filePath = append(filePath, path)
 		return nil
 	})
-	for _, path := range filePath {
-		if err := p.addProfileResult(path); err != nil {
-			return fmt.Errorf("failed to add profile result: %w", err)
+	return filePath
+}
+
+// addProfileResult mounts the run of pprofPath. pprof is the parsed CPU profile, or nil to parse pprofPath.
+func (p *Profiler) addProfileResult(pprofPath string, pprof *profile.Profile) error {
+	p.mountMu.Lock()
+	defer p.mountMu.Unlock()
+	if _, exists := p.loaded[pprofPath]; exists {
+		return nil
+	}
+	var err error
+	if pprof == nil {
+		pprof, err = parseProfileFile(pprofPath)
+		if err != nil {
+			return err
 		}
 	}
-	p.served = true
-	return http.ListenAndServe(fmt.Sprintf(":%d", port), p.mux)
+	currentTime := runID(pprofPath)
+	nav := []navLink{{Name: "cpu", Path: fmt.Sprintf("/%d/", p.lastIdx)}}
+	runtimeProfiles := map[ProfileType]*profile.Profile{}
+	for _, typ := range runtimeProfileTypes {
+		path := filepath.Join(filepath.Dir(pprofPath), profileFileName(typ, currentTime))
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		prof, err := parseProfileFile(path)
+		if err != nil {
+			return err
+		}
+		runtimeProfiles[typ] = prof
+		nav = append(nav, navLink{Name: string(typ), Path: fmt.Sprintf("/%d/%s/", p.lastIdx, typ)})
+	}
+	tracePath := filepath.Join(filepath.Dir(pprofPath), traceFileName(currentTime))
+	_, traceErr := os.Stat(tracePath)
+	if traceErr == nil {
+		nav = append(nav, navLink{Name: "trace", Path: fmt.Sprintf("/%d/trace/", p.lastIdx)})
+	}
+	routes, routeTotal := routeStats(pprof)
+	if routes != nil {
+		nav = append(nav, navLink{Name: "routes", Path: fmt.Sprintf("/%d%s", p.lastIdx, routeEndpoint)})
+	}
+	remotePaths := findRemoteProfiles(filepath.Dir(pprofPath), currentTime)
+	remoteHosts := sortedHosts(remotePaths)
+	remoteProfiles := make([]*profile.Profile, 0, len(remoteHosts))
+	for _, host := range remoteHosts {
+		prof, err := parseProfileFile(remotePaths[host])
+		if err != nil {
+			return err
+		}
+		remoteProfiles = append(remoteProfiles, prof)
+		nav = append(nav, navLink{Name: host, Path: fmt.Sprintf("/%d%s%s/", p.lastIdx, remoteHostsEndpoint, host)})
+	}
+	var merged *profile.Profile
+	if len(remoteProfiles) != 0 {
+		merged, err = profile.Merge(append([]*profile.Profile{pprof.Copy()}, remoteProfiles...))
+		if err != nil {
+			return fmt.Errorf("failed to merge remote profiles: %w", err)
+		}
+		nav = append(nav, navLink{Name: "merged", Path: fmt.Sprintf("/%d%s/", p.lastIdx, mergedEndpoint)})
+	}
+	nav = append(nav, navLink{Name: "diff", Path: fmt.Sprintf("%s?target=%d", diffEndpoint, p.lastIdx)})
+	startTime, _ := time.ParseInLocation(fileFormat, currentTime, time.Local)
+	manifest, err := readManifest(filepath.Join(filepath.Dir(pprofPath), manifestFileName(currentTime)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if manifest != nil {
+		startTime = manifest.StartTime
+	}
+	// the mux can't remove the handlers, so the index is not reused even if mounting fails on the way.
+	idx := p.lastIdx
+	p.lastIdx++
+	if err := p.mountProfile(fmt.Sprintf("/%d", idx), pprof, nil, nav, true); err != nil {
+		return err
+	}
+	for _, typ := range runtimeProfileTypes {
+		prof, exists := runtimeProfiles[typ]
+		if !exists {
+			continue
+		}
+		if err := p.mountProfile(fmt.Sprintf("/%d/%s", idx, typ), prof, nil, nav, false); err != nil {
+			return err
+		}
+	}
+	if traceErr == nil {
+		p.mountTrace(fmt.Sprintf("/%d", idx), tracePath, nav)
+	}
+	for i, host := range remoteHosts {
+		if err := p.mountProfile(fmt.Sprintf("/%d%s%s", idx, remoteHostsEndpoint, host), remoteProfiles[i], nil, nav, false); err != nil {
+			return err
+		}
+	}
+	if merged != nil {
+		if err := p.mountProfile(fmt.Sprintf("/%d%s", idx, mergedEndpoint), merged, nil, nav, false); err != nil {
+			return err
+		}
+	}
+	prefix := fmt.Sprintf("/%d", idx)
+	p.mux.Handle(prefix+artifactEndpoint, http.StripPrefix(prefix+artifactEndpoint, &artifactHandler{profiler: p, idx: idx}))
+	if routes != nil {
+		p.mux.Handle(prefix+routeEndpoint, newRouteHandler(prefix, routes, routeTotal, nav))
+	}
+	p.runsMu.Lock()
+	p.runs = append(p.runs, &run{
+		idx:      idx,
+		id:       currentTime,
+		name:     filepath.Base(pprofPath),
+		time:     startTime,
+		cpu:      pprof,
+		links:    nav,
+		manifest: manifest,
+	})
+	p.runsMu.Unlock()
+	p.loaded[pprofPath] = struct{}{}
+	atomic.StoreInt64(&p.redirectHandler.lastIdx, int64(idx))
+	return nil
+}
+
+func cpuProfileFileName(currentTime string) string {
+	return fmt.Sprintf("%s%s.pprof", cpuProfilePrefix, currentTime)
 }
 
-func (p *Profiler) addProfileResult(pprofPath string) error {
-	file, err := os.Open(pprofPath)
+// runID returns the time part of the CPU profile file name which identifies a run.
+func runID(pprofPath string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(filepath.Base(pprofPath), cpuProfilePrefix), ".pprof")
+}
+
+func parseProfileFile(path string) (*profile.Profile, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	pprof, err := profile.Parse(file)
+	defer file.Close()
+	prof, err := profile.Parse(file)
 	if err != nil {
-		return fmt.Errorf("failed to parse pprof: %w", err)
+		return nil, fmt.Errorf("failed to parse pprof: %w", err)
 	}
-	p.redirectHandler.lastIdx = p.lastIdx
+	return prof, nil
+}
+
+// mountProfile serves the pprof web UI for prof under prefix.
+// If base is not nil, prof is compared with base like -diff_base.
+// If redirect is true, top level routes are redirected to the latest mounted profile.
+func (p *Profiler) mountProfile(prefix string, prof, base *profile.Profile, nav []navLink, redirect bool) error {
+	return p.registerProfile(p.mux, prefix, prof, base, nav, redirect)
+}
+
+// profileHandler returns the pprof web UI at prefix without mounting it on the mux.
+func (p *Profiler) profileHandler(prefix string, prof, base *profile.Profile, nav []navLink) (http.Handler, error) {
+	mux := http.NewServeMux()
+	if err := p.registerProfile(mux, prefix, prof, base, nav, false); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+func (p *Profiler) registerProfile(mux *http.ServeMux, prefix string, prof, base *profile.Profile, nav []navLink, redirect bool) error {
 	options := &driver.Options{
-		Fetch:   &fetcher{pprof: pprof},
+		Fetch:   &fetcher{pprof: prof, base: base},
 		UI:      new(ui),
-		Flagset: new(flagSet),
+		Flagset: &flagSet{diffBase: base != nil},
 		HTTPServer: func(args *driver.HTTPServerArgs) error {
-			p.redirectOnce.Do(func() {
-				for route := range args.Handlers {
-					p.mux.Handle(route, p.redirectHandler)
-				}
-			})
+			if redirect {
+				p.redirectOnce.Do(func() {
+					for route := range args.Handlers {
+						if route == "/" {
+							// the root is served by indexHandler.
+							continue
+						}
+						p.mux.Handle(route, p.redirectHandler)
+					}
+				})
+			}
 			for route, handler := range args.Handlers {
 				trimmed := strings.TrimLeft(route, "/")
-				route = fmt.Sprintf("/%d/%s", p.lastIdx, trimmed)
-				p.mux.Handle(route, handler)
+				route = fmt.Sprintf("%s/%s", prefix, trimmed)
+				mux.Handle(route, &navHandler{handler: handler, links: nav})
 			}
 			return nil
 		},
@@ -119,63 +398,246 @@ func (p *Profiler) addProfileResult(pprofPath string) error {
 	if err := driver.PProf(options); err != nil {
 		return fmt.Errorf("failed to run pprof: %w", err)
 	}
-	p.lastIdx++
 	return nil
 }
 
 const (
-	fileFormat = "2006_01_02_15_04_05"
+	fileFormat       = "2006_01_02_15_04_05"
+	cpuProfilePrefix = "pprof_"
 )
 
-func (p *Profiler) Start() error {
+// Start starts profiling. It is the same as StartContext with context.Background().
+func (p *Profiler) Start(opts ...RunOption) error {
+	return p.StartContext(context.Background(), opts...)
+}
+
+// StartContext starts profiling and every SubProfiler.
+// If one of them fails or ctx is done, the already started ones are stopped and the returned MultiError contains every error.
+// It returns an error wrapping ErrInvalidState unless Profiler is idle or in continuous mode. Continuous profiling is paused until StopContext.
+// opts specify the name and labels of the run, and SubProfilers can get them by RunFromContext.
+func (p *Profiler) StartContext(ctx context.Context, opts ...RunOption) error {
+	p.stateMu.Lock()
+	state := p.state
+	if state != StateIdle && state != StateContinuous {
+		p.stateMu.Unlock()
+		return &StateError{Op: "start", State: state}
+	}
+	p.state = StateStarting
+	p.stateMu.Unlock()
+	// continuous profiling is paused during the run because only one CPU profile can be recorded at a time.
+	paused := state == StateContinuous
+	if paused {
+		if err := p.stopContinuous(); err != nil {
+			log.Printf("failed to finish cpu profile segment: %+v", err)
+		}
+	}
+	if err := p.start(ctx, opts); err != nil {
+		p.finishRun(paused)
+		return err
+	}
+	p.stateMu.Lock()
+	p.pausedContinuous = paused
+	p.state = StateRunning
+	p.stateMu.Unlock()
+	return nil
+}
+
+// finishRun changes the state after the run, and resumes continuous profiling if it is paused by Start.
+func (p *Profiler) finishRun(resume bool) {
+	if resume {
+		err := p.startContinuous()
+		if err == nil {
+			p.setState(StateContinuous)
+			return
+		}
+		log.Printf("failed to resume continuous profiling: %+v", err)
+	}
+	p.setState(StateIdle)
+}
+
+func (p *Profiler) start(ctx context.Context, opts []RunOption) error {
 	if err := p.createBaseDirIfNotExists(); err != nil {
 		return err
 	}
-	currentTime := time.Now().Format(fileFormat)
-	pprofFileName := fmt.Sprintf("pprof_%s.pprof", currentTime)
-	pprofFilePath := filepath.Join(p.baseDir, pprofFileName)
+	if err := p.applyRetention(1); err != nil {
+		return err
+	}
+	startTime := time.Now()
+	currentTime := startTime.Format(fileFormat)
+	pprofFilePath := filepath.Join(p.baseDir, cpuProfileFileName(currentTime))
 	f, err := os.Create(pprofFilePath)
 	if err != nil {
 		return fmt.Errorf("failed to create file %s: %w", pprofFilePath, err)
 	}
-	p.pprofFile = f
 	log.Printf("start pprof: report to %s", pprofFilePath)
-	pprof.StartCPUProfile(f)
-	for _, sub := range p.subProfilers {
-		if err := sub.Start(); err != nil {
-			return err
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(pprofFilePath)
+		return fmt.Errorf("failed to start cpu profile: %w", err)
+	}
+	p.stateMu.Lock()
+	p.pprofFile = f
+	p.currentTime = currentTime
+	p.manifest = p.newManifest(startTime)
+	for _, opt := range opts {
+		opt(p.manifest)
+	}
+	p.stateMu.Unlock()
+	ctx = withRun(ctx, newRunStatus(currentTime, pprofFilePath, p.manifest))
+	if err := p.startRuntimeProfiles(); err != nil {
+		return p.rollback(ctx, 0, err)
+	}
+	if err := p.startTrace(); err != nil {
+		return p.rollback(ctx, 0, err)
+	}
+	for idx, sub := range p.subProfilers {
+		if err := startSubProfiler(ctx, idx, sub); err != nil {
+			return p.rollback(ctx, idx, err)
 		}
 	}
 	return nil
 }
 
-func (p *Profiler) Stop() error {
+// rollback stops the first started SubProfilers and profiling, and removes the files of the run.
+func (p *Profiler) rollback(ctx context.Context, started int, cause error) error {
+	errs := MultiError{cause}
+	for idx := started - 1; idx >= 0; idx-- {
+		if err := stopSubProfiler(ctx, idx, p.subProfilers[idx]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.stopTrace()
+	p.stopRuntimeProfiles()
 	pprof.StopCPUProfile()
-	if p.pprofFile != nil {
-		p.pprofFile.Close()
+	p.pprofFile.Close()
+	if err := removeStoredRun(p.baseDir, p.currentTime); err != nil {
+		errs = append(errs, err)
 	}
-	if p.served {
-		if err := p.addProfileResult(p.pprofFile.Name()); err != nil {
-			return fmt.Errorf("failed to add profile result: %w", err)
+	return errs
+}
+
+// Stop stops profiling. It is the same as StopContext with context.Background().
+func (p *Profiler) Stop() error {
+	return p.StopContext(context.Background())
+}
+
+// StopContext stops profiling and every SubProfiler, and adds the result to the web UI.
+// Even if some of SubProfilers fail or ctx is done, the others are stopped and the returned MultiError contains every error.
+// It returns an error wrapping ErrInvalidState unless Profiler is running.
+func (p *Profiler) StopContext(ctx context.Context) error {
+	if err := p.transition("stop", StateRunning, StateStopping); err != nil {
+		return err
+	}
+	err := p.stop(ctx)
+	p.stateMu.Lock()
+	resume := p.pausedContinuous
+	p.pausedContinuous = false
+	p.stateMu.Unlock()
+	p.finishRun(resume)
+	return err
+}
+
+func (p *Profiler) stop(ctx context.Context) error {
+	ctx = withRun(ctx, newRunStatus(p.currentTime, p.pprofFile.Name(), p.manifest))
+	pprof.StopCPUProfile()
+	p.manifest.EndTime = time.Now()
+	p.pprofFile.Close()
+	p.stopRuntimeProfiles()
+	p.stopTrace()
+	var (
+		errs      MultiError
+		artifacts []Artifact
+	)
+	// the reports of the CPU profile and the web UI share the parsed profile.
+	prof, err := parseProfileFile(p.pprofFile.Name())
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		if err := writeRouteReportFile(prof, filepath.Join(p.baseDir, routeReportFileName(p.currentTime))); err != nil {
+			errs = append(errs, err)
+		}
+		exported, err := p.exportProfile(prof)
+		if err != nil {
+			log.Printf("failed to export profile: %+v", err)
+			errs = append(errs, err)
 		}
+		artifacts = append(artifacts, exported...)
 	}
 	for idx, sub := range p.subProfilers {
-		if err := sub.Stop(); err != nil {
-			log.Printf("failed to stop profiler%d: %+v", idx, err)
+		if err := stopSubProfiler(ctx, idx, sub); err != nil {
+			log.Printf("failed to stop %+v", err)
+			errs = append(errs, err)
+		}
+		if reporter, ok := sub.(ArtifactReporter); ok {
+			artifacts = append(artifacts, reporter.Artifacts()...)
 		}
 	}
-	return nil
+	if p.summary != nil && prof != nil {
+		artifact, err := p.writeSummary(ctx, prof)
+		if err != nil {
+			log.Printf("failed to write summary: %+v", err)
+			errs = append(errs, err)
+		}
+		if artifact.Path != "" {
+			artifacts = append(artifacts, artifact)
+		}
+	}
+	if p.storage != nil {
+		stored, err := p.storeArtifacts(ctx, artifacts)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		artifacts = stored
+	}
+	p.manifest.collectProfiles(p.pprofFile.Name(), p.currentTime)
+	// the manifest may already have the benchmark output attached by SetScore.
+	p.manifest.Artifacts = append(p.manifest.Artifacts, artifacts...)
+	if err := p.manifest.write(filepath.Join(p.baseDir, manifestFileName(p.currentTime))); err != nil {
+		errs = append(errs, err)
+	}
+	if p.storage != nil {
+		if err := p.pushRun(ctx, p.currentTime); err != nil {
+			log.Printf("failed to store run: %+v", err)
+			errs = append(errs, err)
+		}
+	}
+	// the result is added after SubProfilers are stopped to mount the profiles of remote hosts.
+	p.mountMu.Lock()
+	served := p.served
+	p.mountMu.Unlock()
+	if served {
+		if err := p.addProfileResult(p.pprofFile.Name(), prof); err != nil {
+			errs = append(errs, fmt.Errorf("failed to add profile result: %w", err))
+		}
+	}
+	p.setRunManifest(filepath.Base(p.pprofFile.Name()), p.manifest)
+	return errs.errorOrNil()
 }
 
+const (
+	profileSource  = "profile"
+	diffBaseSource = "diff_base"
+)
+
 type fetcher struct {
 	pprof *profile.Profile
+	base  *profile.Profile
 }
 
 func (f *fetcher) Fetch(src string, duration, timeout time.Duration) (*profile.Profile, string, error) {
+	if src == diffBaseSource {
+		// pprof scales the base profile in place, so it must not share the instance mounted for the base run.
+		return f.base.Copy(), "", nil
+	}
+	if f.base != nil {
+		return f.pprof.Copy(), "", nil
+	}
 	return f.pprof, "", nil
 }
 
-type flagSet struct{}
+type flagSet struct {
+	diffBase bool
+}
 
 func (s *flagSet) Bool(name string, def bool, usage string) *bool {
 	var v bool
@@ -199,6 +661,10 @@ func (s *flagSet) String(name string, def string, usage string) *string {
 }
 func (s *flagSet) StringList(name string, def string, usage string) *[]*string {
 	var v []*string
+	if name == "diff_base" && s.diffBase {
+		src := diffBaseSource
+		v = append(v, &src)
+	}
 	return &v
 }
 func (s *flagSet) ExtraUsage() string {
@@ -209,7 +675,7 @@ func (s *flagSet) AddExtraUsage(eu string) {
 }
 
 func (s *flagSet) Parse(usage func()) []string {
-	return []string{"-http", "0.0.0.0:0"}
+	return []string{profileSource}
 }
 
 type ui struct{}
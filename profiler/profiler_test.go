@@ -0,0 +1,419 @@
+package profiler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestProfilerRuntimeProfiles(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(
+		dir,
+		profilertools.ProfileTypesOption(
+			profilertools.HeapProfile,
+			profilertools.AllocsProfile,
+			profilertools.GoroutineProfile,
+			profilertools.BlockProfile,
+			profilertools.MutexProfile,
+		),
+	)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"pprof", "heap", "allocs", "goroutine", "block", "mutex"} {
+		matches, err := filepath.Glob(filepath.Join(dir, prefix+"_*.pprof"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 {
+			t.Fatalf("expected one %s profile but got %v", prefix, matches)
+		}
+		if _, err := os.Stat(matches[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProfilerRuntimeProfileRates(t *testing.T) {
+	prev := runtime.SetMutexProfileFraction(5)
+	defer runtime.SetMutexProfileFraction(prev)
+	p := profilertools.NewProfiler(
+		t.TempDir(),
+		profilertools.ProfileTypesOption(profilertools.BlockProfile, profilertools.MutexProfile),
+		profilertools.BaseBlockProfileRateOption(0),
+	)
+	p.AddProfiler(&fakeSubProfiler{name: "failed", startErr: errors.New("failed")})
+	if err := p.Start(); err == nil {
+		t.Fatal("expected error")
+	}
+	// the rates enabled by Start are reset on rollback.
+	if fraction := runtime.SetMutexProfileFraction(-1); fraction != 5 {
+		t.Fatalf("unexpected mutex profile fraction: %d", fraction)
+	}
+}
+
+func TestProfilerTrace(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.TraceOption(0, 0))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "trace_*.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected one trace but got %v", matches)
+	}
+	info, err := os.Stat(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() == 0 {
+		t.Fatal("trace is empty")
+	}
+}
+
+func TestProfilerTraceViewerStop(t *testing.T) {
+	src := t.TempDir()
+	p := profilertools.NewProfiler(src, profilertools.TraceOption(0, 0))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := p.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the run is copied as an old run to be deleted by the retention policy.
+	dir := t.TempDir()
+	for _, prefix := range []string{"pprof", "trace"} {
+		matches, err := filepath.Glob(filepath.Join(src, prefix+"_"+runs[0].ID+".*"))
+		if err != nil || len(matches) != 1 {
+			t.Fatalf("unexpected %s files: %v %v", prefix, matches, err)
+		}
+		b, err := os.ReadFile(matches[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.Replace(filepath.Base(matches[0]), runs[0].ID, "2020_01_01_00_00_01", 1)
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p = profilertools.NewProfiler(dir, profilertools.TraceOption(0, 0), profilertools.RetentionOption(profilertools.RetentionPolicy{KeepLast: 1}))
+	h, err := profilertools.ServeHandler(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := func(idx int) *os.Process {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%d/trace/view", idx), nil))
+		if rec.Code != http.StatusFound {
+			t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+		}
+		proc := profilertools.TraceViewerProcess(p)
+		if proc == nil {
+			t.Fatal("go tool trace is not started")
+		}
+		return proc
+	}
+	assertKilled := func(proc *os.Process) {
+		t.Helper()
+		if profilertools.TraceViewerProcess(p) != nil {
+			t.Fatal("the trace viewer is not stopped")
+		}
+		// the process is already waited.
+		if err := proc.Signal(syscall.Signal(0)); err == nil {
+			t.Fatal("go tool trace is still running")
+		}
+	}
+
+	// the viewer of the run deleted by the retention policy is stopped.
+	proc := view(0)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	assertKilled(proc)
+
+	proc = view(1)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertKilled(proc)
+}
+
+func TestProfilerManifest(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.LabelsOption(map[string]string{"branch": "main"}))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "manifest_*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected one manifest but got %v", matches)
+	}
+	b, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m profilertools.Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Labels["branch"] != "main" {
+		t.Fatalf("unexpected labels: %v", m.Labels)
+	}
+	if m.GoVersion != runtime.Version() {
+		t.Fatalf("unexpected go version: %s", m.GoVersion)
+	}
+	if m.EndTime.Before(m.StartTime) {
+		t.Fatalf("unexpected run window: %s - %s", m.StartTime, m.EndTime)
+	}
+	if _, exists := m.Profiles["cpu"]; !exists {
+		t.Fatalf("cpu profile is not recorded: %v", m.Profiles)
+	}
+}
+
+func TestProfilerRetention(t *testing.T) {
+	dir := t.TempDir()
+	ids := []string{
+		"2020_01_01_00_00_01",
+		"2020_01_01_00_00_02",
+		"2020_01_01_00_00_03",
+		"2020_01_01_00_00_04",
+	}
+	for _, id := range ids {
+		for _, name := range []string{"pprof_" + id + ".pprof", "manifest_" + id + ".json"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := profilertools.NewProfiler(dir, profilertools.RetentionOption(profilertools.RetentionPolicy{
+		KeepLast: 2,
+		Pinned:   []string{ids[0]},
+	}))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		exists bool
+	}{
+		{name: "pprof_" + ids[0] + ".pprof", exists: true},
+		{name: "pprof_" + ids[1] + ".pprof", exists: false},
+		{name: "manifest_" + ids[2] + ".json", exists: false},
+		{name: "pprof_" + ids[3] + ".pprof", exists: true},
+		{name: "manifest_" + ids[3] + ".json", exists: true},
+		{name: "other.txt", exists: true},
+	} {
+		_, err := os.Stat(filepath.Join(dir, tc.name))
+		if exists := err == nil; exists != tc.exists {
+			t.Fatalf("%s: expected exists=%v but got %v", tc.name, tc.exists, exists)
+		}
+	}
+}
+
+// writeTestRuns records a CPU profile once and writes it to dir as the runs of ids.
+func writeTestRuns(t *testing.T, dir string, ids ...string) {
+	t.Helper()
+	src := t.TempDir()
+	p := profilertools.NewProfiler(src)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := p.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(runs[0].Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if err := os.WriteFile(filepath.Join(dir, "pprof_"+id+".pprof"), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProfilerRetentionServed(t *testing.T) {
+	dir := t.TempDir()
+	ids := []string{"2020_01_01_00_00_01", "2020_01_01_00_00_02"}
+	writeTestRuns(t, dir, ids...)
+	p := profilertools.NewProfiler(dir, profilertools.RetentionOption(profilertools.RetentionPolicy{KeepLast: 2}))
+	if _, err := profilertools.ServeHandler(p); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := profilertools.LoadedRunIDs(p); len(loaded) != 2 {
+		t.Fatalf("unexpected runs: %v", loaded)
+	}
+	// Start makes room for the new run by deleting the oldest one.
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	loaded := profilertools.LoadedRunIDs(p)
+	if len(loaded) != 1 || loaded[0] != ids[1] {
+		t.Fatalf("the deleted run is still served: %v", loaded)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := profilertools.LoadedRunIDs(p); len(loaded) != 2 || loaded[0] != ids[1] {
+		t.Fatalf("unexpected runs: %v", loaded)
+	}
+}
+
+func TestProfilerState(t *testing.T) {
+	p := profilertools.NewProfiler(t.TempDir())
+	if err := p.Stop(); !errors.Is(err, profilertools.ErrInvalidState) {
+		t.Fatalf("expected invalid state error but got %v", err)
+	}
+	if status := p.Status(); status.State != profilertools.StateIdle || status.Run != nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(); !errors.Is(err, profilertools.ErrInvalidState) {
+		t.Fatalf("expected invalid state error but got %v", err)
+	}
+	status := p.Status()
+	if status.State != profilertools.StateRunning || status.Run == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if status := p.Status(); status.State != profilertools.StateIdle {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+type fakeSubProfiler struct {
+	name     string
+	startErr error
+	stopErr  error
+	block    chan struct{}
+	stopped  bool
+	// stopCh is closed by Stop if it isn't nil.
+	stopCh chan struct{}
+}
+
+func (p *fakeSubProfiler) Name() string { return p.name }
+
+func (p *fakeSubProfiler) Start() error {
+	if p.block != nil {
+		<-p.block
+	}
+	return p.startErr
+}
+
+func (p *fakeSubProfiler) Stop() error {
+	p.stopped = true
+	if p.stopCh != nil {
+		close(p.stopCh)
+	}
+	return p.stopErr
+}
+
+func TestProfilerStartRollback(t *testing.T) {
+	dir := t.TempDir()
+	first := &fakeSubProfiler{name: "first"}
+	second := &fakeSubProfiler{name: "second", startErr: errors.New("failed")}
+	p := profilertools.NewProfiler(dir)
+	p.AddProfiler(first)
+	p.AddProfiler(second)
+	err := p.Start()
+	var subErr *profilertools.SubProfilerError
+	if !errors.As(err, &subErr) || subErr.Name != "second" {
+		t.Fatalf("expected error of second profiler but got %v", err)
+	}
+	if !first.stopped {
+		t.Fatal("first profiler is not stopped")
+	}
+	if state := p.Status().State; state != profilertools.StateIdle {
+		t.Fatalf("unexpected state: %s", state)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("files of the failed run remain: %v", matches)
+	}
+}
+
+func TestProfilerStartContextDeadline(t *testing.T) {
+	block := make(chan struct{})
+	slow := &fakeSubProfiler{name: "slow", block: block, stopCh: make(chan struct{})}
+	p := profilertools.NewProfiler(t.TempDir())
+	p.AddProfiler(slow)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.StartContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded but got %v", err)
+	}
+	// Start returns after the rollback, and then the sub profiler must be stopped.
+	close(block)
+	select {
+	case <-slow.stopCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the sub profiler started late is not stopped")
+	}
+}
+
+func TestProfilerStopErrors(t *testing.T) {
+	p := profilertools.NewProfiler(t.TempDir())
+	p.AddProfiler(&fakeSubProfiler{name: "first", stopErr: errors.New("failed")})
+	p.AddProfiler(&fakeSubProfiler{name: "second", stopErr: errors.New("failed")})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	err := p.Stop()
+	var errs profilertools.MultiError
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected errors of two profilers but got %v", err)
+	}
+}
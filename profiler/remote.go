@@ -0,0 +1,239 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	remoteProfilePrefix = "remote_"
+	remoteHostsEndpoint = "/hosts/"
+	mergedEndpoint      = "/merged"
+)
+
+// RemoteHost is an application instance profiled in the same window as the local process.
+// The instance must register the routes by ControlRoutesOption with Auth.
+type RemoteHost struct {
+	// Name is shown in the web UI and used for the file name. It defaults to the host of URL.
+	Name string
+	// URL is the base URL of the instance such as http://app1:1323 .
+	URL string
+	// Auth is the credential of the control routes of the instance.
+	Auth Credential
+}
+
+var invalidHostNameRe = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func (h RemoteHost) name() string {
+	name := h.Name
+	if name == "" {
+		if u, err := url.Parse(h.URL); err == nil && u.Host != "" {
+			name = u.Host
+		} else {
+			name = h.URL
+		}
+	}
+	return invalidHostNameRe.ReplaceAllString(name, "-")
+}
+
+// RemoteHostsOption makes Profiler a coordinator: Start and Stop also start and stop the Profilers of hosts,
+// and their CPU profiles are stored as remote_<time>_<host>.pprof . The web UI shows the profile of every host and the merged one.
+func RemoteHostsOption(hosts ...RemoteHost) ProfilerOption {
+	return func(p *Profiler) {
+		p.AddProfiler(&remoteProfiler{profiler: p, hosts: hosts, client: http.DefaultClient})
+	}
+}
+
+func remoteProfileFileName(currentTime, host string) string {
+	return fmt.Sprintf("%s%s_%s.pprof", remoteProfilePrefix, currentTime, host)
+}
+
+// findRemoteProfiles returns the paths of the remote profiles of the run by host name.
+func findRemoteProfiles(dir, currentTime string) map[string]string {
+	prefix := remoteProfilePrefix + currentTime + "_"
+	matches, _ := filepath.Glob(filepath.Join(dir, prefix+"*.pprof"))
+	profiles := map[string]string{}
+	for _, path := range matches {
+		profiles[strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), prefix), ".pprof")] = path
+	}
+	return profiles
+}
+
+func sortedHosts(profiles map[string]string) []string {
+	hosts := make([]string, 0, len(profiles))
+	for host := range profiles {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// remoteProfiler starts and stops the Profilers of the remote hosts concurrently.
+type remoteProfiler struct {
+	profiler    *Profiler
+	hosts       []RemoteHost
+	client      *http.Client
+	currentTime string
+	runIDs      []string
+}
+
+func (r *remoteProfiler) Name() string {
+	return "remote-hosts"
+}
+
+func (r *remoteProfiler) Start() error {
+	return r.StartContext(context.Background())
+}
+
+func (r *remoteProfiler) Stop() error {
+	return r.StopContext(context.Background())
+}
+
+func (r *remoteProfiler) StartContext(ctx context.Context) error {
+	r.profiler.stateMu.Lock()
+	r.currentTime = r.profiler.currentTime
+	r.profiler.stateMu.Unlock()
+	runIDs := make([]string, len(r.hosts))
+	errs := r.forEachHost(func(idx int, host RemoteHost) error {
+		id, err := r.startHost(ctx, host)
+		runIDs[idx] = id
+		return err
+	})
+	if len(errs) == 0 {
+		r.runIDs = runIDs
+		return nil
+	}
+	// stop the hosts which have started.
+	r.forEachHost(func(idx int, host RemoteHost) error {
+		if runIDs[idx] == "" {
+			return nil
+		}
+		return r.post(ctx, host, "/stop", nil, nil)
+	})
+	return errs
+}
+
+func (r *remoteProfiler) StopContext(ctx context.Context) error {
+	return r.forEachHost(func(idx int, host RemoteHost) error {
+		return r.stopHost(ctx, host, r.runIDs[idx])
+	}).errorOrNil()
+}
+
+func (r *remoteProfiler) forEachHost(f func(int, RemoteHost) error) MultiError {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(r.hosts))
+	)
+	for idx, host := range r.hosts {
+		wg.Add(1)
+		go func(idx int, host RemoteHost) {
+			defer wg.Done()
+			if err := f(idx, host); err != nil {
+				errs[idx] = &SubProfilerError{Name: host.name(), Err: err}
+			}
+		}(idx, host)
+	}
+	wg.Wait()
+	var ret MultiError
+	for _, err := range errs {
+		if err != nil {
+			ret = append(ret, err)
+		}
+	}
+	return ret
+}
+
+func (r *remoteProfiler) startHost(ctx context.Context, host RemoteHost) (string, error) {
+	// the remote run has the same name and labels as the local run.
+	var req StartRequest
+	if run, ok := RunFromContext(ctx); ok {
+		req = StartRequest{Name: run.Name, Labels: run.Labels}
+	}
+	var status Status
+	if err := r.post(ctx, host, "/start", &req, &status); err != nil {
+		return "", err
+	}
+	if status.Run == nil {
+		return "", fmt.Errorf("failed to get the run started on %s", host.URL)
+	}
+	return status.Run.ID, nil
+}
+
+func (r *remoteProfiler) stopHost(ctx context.Context, host RemoteHost, id string) error {
+	if err := r.post(ctx, host, "/stop", nil, nil); err != nil {
+		return err
+	}
+	res, err := r.do(ctx, http.MethodGet, host, fmt.Sprintf("/runs/%s/profile", id), nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	path := filepath.Join(r.profiler.baseDir, remoteProfileFileName(r.currentTime, host.name()))
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, res.Body); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to download profile from %s: %w", host.URL, err)
+	}
+	return f.Close()
+}
+
+func (r *remoteProfiler) post(ctx context.Context, host RemoteHost, path string, body, v interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	res, err := r.do(ctx, http.MethodPost, host, path, reqBody)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", host.URL, err)
+	}
+	return nil
+}
+
+// do sends a request to the control routes of host. It returns an error unless the status is 200.
+func (r *remoteProfiler) do(ctx context.Context, method string, host RemoteHost, path string, body io.Reader) (*http.Response, error) {
+	endpoint := strings.TrimSuffix(host.URL, "/") + controlEndpoint + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	host.Auth.setTo(req)
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %w", endpoint, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("failed to request %s: %s: %s", endpoint, res.Status, strings.TrimSpace(string(body)))
+	}
+	return res, nil
+}
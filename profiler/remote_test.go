@@ -0,0 +1,128 @@
+package profiler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+type fakeRemoteHost struct {
+	profile  []byte
+	startErr bool
+	started  int32
+	stopped  int32
+}
+
+func (h *fakeRemoteHost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/debug/profiler/start":
+		if h.startErr {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		atomic.AddInt32(&h.started, 1)
+		json.NewEncoder(w).Encode(profilertools.Status{
+			State: profilertools.StateRunning,
+			Run:   &profilertools.RunStatus{ID: "2022_07_23_10_00_00"},
+		})
+	case "/debug/profiler/stop":
+		atomic.AddInt32(&h.stopped, 1)
+		json.NewEncoder(w).Encode(profilertools.Status{State: profilertools.StateIdle})
+	case "/debug/profiler/runs/2022_07_23_10_00_00/profile":
+		w.Write(h.profile)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func cpuProfile(t *testing.T) []byte {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "pprof_*.pprof"))
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("failed to find cpu profile: %v %v", matches, err)
+	}
+	b, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestProfilerRemoteHosts(t *testing.T) {
+	host := &fakeRemoteHost{profile: cpuProfile(t)}
+	srv := httptest.NewServer(host)
+	defer srv.Close()
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.RemoteHostsOption(profilertools.RemoteHost{
+		Name: "app1",
+		URL:  srv.URL,
+		Auth: profilertools.TokenCredential("secret"),
+	}))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "remote_*_app1.pprof"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected one remote profile but got %v", matches)
+	}
+	runs, err := p.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Manifest.Profiles["remote:app1"] != matches[0] {
+		t.Fatalf("remote profile is not recorded: %+v", runs)
+	}
+}
+
+func TestProfilerRemoteHostsStartRollback(t *testing.T) {
+	first := &fakeRemoteHost{}
+	firstSrv := httptest.NewServer(first)
+	defer firstSrv.Close()
+	second := &fakeRemoteHost{startErr: true}
+	secondSrv := httptest.NewServer(second)
+	defer secondSrv.Close()
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.RemoteHostsOption(
+		profilertools.RemoteHost{Name: "app1", URL: firstSrv.URL, Auth: profilertools.TokenCredential("secret")},
+		profilertools.RemoteHost{Name: "app2", URL: secondSrv.URL, Auth: profilertools.TokenCredential("secret")},
+	))
+	err := p.Start()
+	var subErr *profilertools.SubProfilerError
+	if !errors.As(err, &subErr) {
+		t.Fatalf("expected sub profiler error but got %v", err)
+	}
+	if atomic.LoadInt32(&first.stopped) != 1 {
+		t.Fatal("started host is not stopped")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("files of the failed run remain: %v", entries)
+	}
+}
@@ -0,0 +1,203 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which stored runs in baseDir are deleted.
+// Zero values mean no limit. Pinned runs are never deleted and are specified by
+// the time of the run ( e.g. 2006_01_02_15_04_05 ) or the file name of the CPU profile.
+type RetentionPolicy struct {
+	KeepLast int
+	MaxBytes int64
+	MaxAge   time.Duration
+	Pinned   []string
+}
+
+// RetentionOption applies policy to the runs stored in baseDir on Start and on ListenAndServe.
+func RetentionOption(policy RetentionPolicy) ProfilerOption {
+	return func(p *Profiler) {
+		p.retention = &policy
+	}
+}
+
+// runFilePrefixes is the list of prefixes of the files which belong to a run.
+var runFilePrefixes = map[string]struct{}{
+	"pprof":      {},
+	"heap":       {},
+	"allocs":     {},
+	"goroutine":  {},
+	"block":      {},
+	"mutex":      {},
+	"trace":      {},
+	"manifest":   {},
+	"routes":     {},
+	"remote":     {},
+	"summary":    {},
+	"flamegraph": {},
+	"callgraph":  {},
+	"benchmark":  {},
+	"artifact":   {},
+}
+
+// runFileRe matches <prefix>_<time>.<ext> and <prefix>_<time>_<host>.<ext> ( the profiles of remote hosts ).
+var runFileRe = regexp.MustCompile(`^([a-z]+)_(\d{4}_\d{2}_\d{2}_\d{2}_\d{2}_\d{2})(?:_[A-Za-z0-9.-]+)?\.[a-z.]+$`)
+
+type storedRun struct {
+	id    string
+	time  time.Time
+	files []string
+	size  int64
+}
+
+func listStoredRuns(dir string) ([]*storedRun, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	runMap := map[string]*storedRun{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matched := runFileRe.FindStringSubmatch(entry.Name())
+		if matched == nil {
+			continue
+		}
+		if _, exists := runFilePrefixes[matched[1]]; !exists {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		id := matched[2]
+		r, exists := runMap[id]
+		if !exists {
+			t, err := time.ParseInLocation(fileFormat, id, time.Local)
+			if err != nil {
+				continue
+			}
+			r = &storedRun{id: id, time: t}
+			runMap[id] = r
+		}
+		r.files = append(r.files, filepath.Join(dir, entry.Name()))
+		r.size += info.Size()
+	}
+	runs := make([]*storedRun, 0, len(runMap))
+	for _, r := range runMap {
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].time.After(runs[j].time)
+	})
+	return runs, nil
+}
+
+func (policy *RetentionPolicy) isPinned(id string) bool {
+	for _, pinned := range policy.Pinned {
+		if pinned == id || pinned == cpuProfileFileName(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// expired returns the runs to delete. runs must be sorted from newest to oldest.
+// reserved is the number of runs that will be added right after this, and they are counted by KeepLast.
+func (policy *RetentionPolicy) expired(runs []*storedRun, now time.Time, reserved int) []*storedRun {
+	var totalSize int64
+	for _, r := range runs {
+		if policy.isPinned(r.id) {
+			totalSize += r.size
+		}
+	}
+	var (
+		expired []*storedRun
+		kept    = reserved
+	)
+	for _, r := range runs {
+		if policy.isPinned(r.id) {
+			continue
+		}
+		switch {
+		case policy.MaxAge > 0 && now.Sub(r.time) > policy.MaxAge:
+			expired = append(expired, r)
+		case policy.KeepLast > 0 && kept >= policy.KeepLast:
+			expired = append(expired, r)
+		case policy.MaxBytes > 0 && totalSize+r.size > policy.MaxBytes:
+			expired = append(expired, r)
+		default:
+			kept++
+			totalSize += r.size
+		}
+	}
+	return expired
+}
+
+func (p *Profiler) applyRetention(reserved int) error {
+	if p.retention == nil {
+		return nil
+	}
+	runs, err := listStoredRuns(p.baseDir)
+	if err != nil {
+		return err
+	}
+	expired := p.retention.expired(runs, time.Now(), reserved)
+	if len(expired) == 0 {
+		return nil
+	}
+	deleted := map[string]struct{}{}
+	for _, r := range expired {
+		log.Printf("delete profiling run %s", r.id)
+		p.traceViewer.stopIfViewing(r.files)
+		if p.storage != nil {
+			if err := p.deleteStoredRun(context.Background(), r); err != nil {
+				return err
+			}
+		}
+		if err := r.remove(); err != nil {
+			return err
+		}
+		deleted[r.id] = struct{}{}
+	}
+	p.runsMu.Lock()
+	defer p.runsMu.Unlock()
+	loaded := make([]*run, 0, len(p.runs))
+	for _, r := range p.runs {
+		if _, exists := deleted[r.id]; !exists {
+			loaded = append(loaded, r)
+		}
+	}
+	p.runs = loaded
+	return nil
+}
+
+func removeStoredRun(dir, id string) error {
+	runs, err := listStoredRuns(dir)
+	if err != nil {
+		return err
+	}
+	for _, r := range runs {
+		if r.id == id {
+			return r.remove()
+		}
+	}
+	return nil
+}
+
+func (r *storedRun) remove() error {
+	for _, file := range r.files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goccy/echo-tools/middleware"
+	"github.com/google/pprof/profile"
+)
+
+const (
+	routeEndpoint  = "/routes"
+	unlabeledRoute = "(unlabeled)"
+)
+
+func routeReportFileName(currentTime string) string {
+	return fmt.Sprintf("routes_%s.txt", currentTime)
+}
+
+// routeStat is the CPU time of a route labeled by middleware.PprofLabels.
+type routeStat struct {
+	Method string
+	Route  string
+	CPU    time.Duration
+}
+
+func (s *routeStat) name() string {
+	if s.Route == unlabeledRoute {
+		return s.Route
+	}
+	return fmt.Sprintf("%s %s", s.Method, s.Route)
+}
+
+// routeStats aggregates the CPU time of prof by route. It returns nil if prof has no route label.
+func routeStats(prof *profile.Profile) ([]*routeStat, time.Duration) {
+	valueIdx := -1
+	for idx, typ := range prof.SampleType {
+		if typ.Type == "cpu" && typ.Unit == "nanoseconds" {
+			valueIdx = idx
+		}
+	}
+	if valueIdx < 0 {
+		return nil, 0
+	}
+	var (
+		total    time.Duration
+		labeled  bool
+		statMap  = map[string]*routeStat{}
+		statKeys []string
+	)
+	for _, sample := range prof.Sample {
+		value := time.Duration(sample.Value[valueIdx])
+		total += value
+		route, method := unlabeledRoute, ""
+		if routes := sample.Label[middleware.RouteLabel]; len(routes) != 0 {
+			route = routes[0]
+			labeled = true
+			if methods := sample.Label[middleware.MethodLabel]; len(methods) != 0 {
+				method = methods[0]
+			}
+		}
+		key := method + " " + route
+		stat, exists := statMap[key]
+		if !exists {
+			stat = &routeStat{Method: method, Route: route}
+			statMap[key] = stat
+			statKeys = append(statKeys, key)
+		}
+		stat.CPU += value
+	}
+	if !labeled {
+		return nil, total
+	}
+	stats := make([]*routeStat, 0, len(statKeys))
+	for _, key := range statKeys {
+		stats = append(stats, statMap[key])
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].CPU > stats[j].CPU
+	})
+	return stats, total
+}
+
+// routeTagFocus returns the value of the tagfocus parameter of the pprof web UI which matches the route.
+func routeTagFocus(route string) string {
+	return fmt.Sprintf("%s=^%s$", middleware.RouteLabel, regexp.QuoteMeta(route))
+}
+
+func writeRouteReport(w io.Writer, stats []*routeStat, total time.Duration) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "route\tcpu(s)\tcpu(%)")
+	for _, stat := range stats {
+		fmt.Fprintf(tw, "%s\t%.3f\t%.1f\n", stat.name(), stat.CPU.Seconds(), percent(int64(stat.CPU), int64(total)))
+	}
+	return tw.Flush()
+}
+
+func percent(v, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(v) / float64(total) * 100
+}
+
+// writeRouteReportFile writes the CPU time by route of prof if it is labeled.
+func writeRouteReportFile(prof *profile.Profile, path string) error {
+	stats, total := routeStats(prof)
+	if stats == nil {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	defer f.Close()
+	if err := writeRouteReport(f, stats, total); err != nil {
+		return fmt.Errorf("failed to write route report: %w", err)
+	}
+	return nil
+}
+
+var routeTmpl = newPageTemplate("route", `<!DOCTYPE html>
+<html>
+<head>
+<title>routes</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+{{ template "nav" .Links }}
+<h2>cpu by route</h2>
+<table>
+<tr><th>route</th><th>cpu(s)</th><th>cpu(%)</th><th>views</th></tr>
+{{- range .Routes }}
+<tr>
+  <td>{{ .Name }}</td>
+  <td>{{ printf "%.3f" .Seconds }}</td>
+  <td>{{ printf "%.1f" .Percent }}</td>
+  <td>{{ if .TagFocus }}<a href="{{ $.Prefix }}/flamegraph?tf={{ .TagFocus }}">flamegraph</a> <a href="{{ $.Prefix }}/top?tf={{ .TagFocus }}">top</a>{{ end }}</td>
+</tr>
+{{- end }}
+</table>
+</body>
+</html>`)
+
+type routeRow struct {
+	Name     string
+	Seconds  float64
+	Percent  float64
+	TagFocus string
+}
+
+type routeHandler struct {
+	prefix string
+	rows   []routeRow
+	links  []navLink
+}
+
+func newRouteHandler(prefix string, stats []*routeStat, total time.Duration, links []navLink) *routeHandler {
+	rows := make([]routeRow, 0, len(stats))
+	for _, stat := range stats {
+		var tagFocus string
+		if stat.Route != unlabeledRoute {
+			tagFocus = routeTagFocus(stat.Route)
+		}
+		rows = append(rows, routeRow{
+			Name:     stat.name(),
+			Seconds:  stat.CPU.Seconds(),
+			Percent:  percent(int64(stat.CPU), int64(total)),
+			TagFocus: tagFocus,
+		})
+	}
+	return &routeHandler{prefix: prefix, rows: rows, links: links}
+}
+
+func (h *routeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	if err := routeTmpl.Execute(w, struct {
+		Prefix string
+		Routes []routeRow
+		Links  []navLink
+	}{
+		Prefix: h.prefix,
+		Routes: h.rows,
+		Links:  h.links,
+	}); err != nil {
+		log.Printf("failed to render route page: %+v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package profiler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	middlewaretools "github.com/goccy/echo-tools/middleware"
+	profilertools "github.com/goccy/echo-tools/profiler"
+	"github.com/labstack/echo/v4"
+)
+
+func TestProfilerRouteReport(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir)
+	e := echo.New()
+	e.Use(middlewaretools.PprofLabels())
+	e.GET("/users/:id", func(c echo.Context) error {
+		deadline := time.Now().Add(500 * time.Millisecond)
+		n := 0
+		for time.Now().Before(deadline) {
+			n++
+		}
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "routes_*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected one route report but got %v", matches)
+	}
+	b, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "GET /users/:id") {
+		t.Fatalf("route is not reported:\n%s", b)
+	}
+}
+
+func TestPprofLabelsReturnsHandlerError(t *testing.T) {
+	e := echo.New()
+	var handlerErr error
+	// an outer middleware such as logger receives the error of the handler.
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			handlerErr = next(c)
+			return handlerErr
+		}
+	})
+	e.Use(middlewaretools.PprofLabels())
+	e.GET("/error", func(c echo.Context) error {
+		return echo.ErrForbidden
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/error", nil))
+	if handlerErr != echo.ErrForbidden || rec.Code != http.StatusForbidden {
+		t.Fatalf("unexpected error: %v %d", handlerErr, rec.Code)
+	}
+}
+
+func TestPprofLabelsStatus(t *testing.T) {
+	e := echo.New()
+	var labels []string
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+		ctx := c.Request().Context()
+		for _, key := range []string{middlewaretools.RouteLabel, middlewaretools.MethodLabel, middlewaretools.StatusLabel} {
+			v, _ := pprof.Label(ctx, key)
+			labels = append(labels, v)
+		}
+		e.DefaultHTTPErrorHandler(err, c)
+	}
+	e.Use(middlewaretools.PprofLabels())
+	e.GET("/error", func(c echo.Context) error {
+		return echo.ErrForbidden
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/error", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	if strings.Join(labels, " ") != "/error GET 4xx" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+}
@@ -0,0 +1,132 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RunOption specifies the name and labels of a run started by Start.
+type RunOption func(*Manifest)
+
+// RunNameOption names the run. The name is shown in the web UI and used for the titles of the reports.
+func RunNameOption(name string) RunOption {
+	return func(m *Manifest) {
+		m.Name = name
+	}
+}
+
+// RunLabelsOption adds labels such as branch=cache-users to the run. They override the labels specified by LabelsOption.
+func RunLabelsOption(labels map[string]string) RunOption {
+	return func(m *Manifest) {
+		if m.Labels == nil {
+			m.Labels = map[string]string{}
+		}
+		for k, v := range labels {
+			m.Labels[k] = v
+		}
+	}
+}
+
+func newRunStatus(id, pprofPath string, m *Manifest) *RunStatus {
+	labels := make(map[string]string, len(m.Labels))
+	for k, v := range m.Labels {
+		labels[k] = v
+	}
+	return &RunStatus{
+		ID:        id,
+		Name:      m.Name,
+		StartTime: m.StartTime,
+		Profile:   pprofPath,
+		Labels:    labels,
+	}
+}
+
+// Title returns the name ( or the id if it is not named ) and the labels of the run.
+func (s *RunStatus) Title() string {
+	title := s.Name
+	if title == "" {
+		title = s.ID
+	}
+	if labels := formatLabels(s.Labels); labels != "" {
+		title = fmt.Sprintf("%s (%s)", title, labels)
+	}
+	return title
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, labels[k]))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+type runContextKey struct{}
+
+func withRun(ctx context.Context, run *RunStatus) context.Context {
+	return context.WithValue(ctx, runContextKey{}, run)
+}
+
+// RunFromContext returns the run which is being started or stopped. Profiler passes it to SubProfilers implementing ContextSubProfiler.
+func RunFromContext(ctx context.Context) (*RunStatus, bool) {
+	run, ok := ctx.Value(runContextKey{}).(*RunStatus)
+	return run, ok
+}
+
+// reportTitle returns the title of a report shared by gist and Discord.
+func reportTitle(kind, runTitle string) string {
+	if runTitle == "" {
+		return kind
+	}
+	return fmt.Sprintf("%s %s", kind, runTitle)
+}
+
+// RunFilter selects runs. Name matches the runs whose name contains it, and Labels matches the runs which have all of them.
+type RunFilter struct {
+	Name   string
+	Labels map[string]string
+}
+
+// ParseRunFilter parses the filter from the query parameters name and label ( key=value, repeatable ).
+func ParseRunFilter(name string, labels []string) (RunFilter, error) {
+	filter := RunFilter{Name: name}
+	for _, label := range labels {
+		if label == "" {
+			continue
+		}
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			return filter, fmt.Errorf("invalid label %q: label must be key=value", label)
+		}
+		if filter.Labels == nil {
+			filter.Labels = map[string]string{}
+		}
+		filter.Labels[kv[0]] = kv[1]
+	}
+	return filter, nil
+}
+
+func (f RunFilter) match(m *Manifest) bool {
+	if f.Name == "" && len(f.Labels) == 0 {
+		return true
+	}
+	if m == nil {
+		return false
+	}
+	if !strings.Contains(m.Name, f.Name) {
+		return false
+	}
+	for k, v := range f.Labels {
+		if m.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package profiler_test
+
+import (
+	"context"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+type runRecorder struct {
+	started *profilertools.RunStatus
+	stopped *profilertools.RunStatus
+}
+
+func (r *runRecorder) Start() error { return nil }
+func (r *runRecorder) Stop() error  { return nil }
+
+func (r *runRecorder) StartContext(ctx context.Context) error {
+	r.started, _ = profilertools.RunFromContext(ctx)
+	return nil
+}
+
+func (r *runRecorder) StopContext(ctx context.Context) error {
+	r.stopped, _ = profilertools.RunFromContext(ctx)
+	return nil
+}
+
+func TestProfilerRunNameAndLabels(t *testing.T) {
+	p := profilertools.NewProfiler(t.TempDir(), profilertools.LabelsOption(map[string]string{"env": "dev", "branch": "main"}))
+	recorder := &runRecorder{}
+	p.AddProfiler(recorder)
+	if err := p.Start(
+		profilertools.RunNameOption("users cache"),
+		profilertools.RunLabelsOption(map[string]string{"branch": "cache-users", "note": "index added"}),
+	); err != nil {
+		t.Fatal(err)
+	}
+	run := p.Status().Run
+	if run == nil || run.Name != "users cache" {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	expectedTitle := "users cache (branch=cache-users, env=dev, note=index added)"
+	for _, r := range []*profilertools.RunStatus{recorder.started, recorder.stopped} {
+		if r == nil || r.Title() != expectedTitle {
+			t.Fatalf("unexpected run in context: %+v", r)
+		}
+	}
+
+	runs, err := p.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+	m := runs[0].Manifest
+	if m.Name != "users cache" || m.Labels["branch"] != "cache-users" || m.Labels["env"] != "dev" || m.Labels["note"] != "index added" {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		count  int
+	}{
+		{count: 1},
+		{name: "cache", count: 1},
+		{name: "posts", count: 0},
+		{labels: []string{"branch=cache-users", "note=index added"}, count: 1},
+		{labels: []string{"branch=main"}, count: 0},
+	} {
+		filter, err := profilertools.ParseRunFilter(tc.name, tc.labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs, err := p.FilterRuns(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) != tc.count {
+			t.Fatalf("name=%q labels=%v: expected %d runs but got %d", tc.name, tc.labels, tc.count, len(runs))
+		}
+	}
+	if _, err := profilertools.ParseRunFilter("", []string{"branch"}); err == nil {
+		t.Fatal("expected error for invalid label")
+	}
+}
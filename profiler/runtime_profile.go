@@ -0,0 +1,159 @@
+package profiler
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/google/pprof/profile"
+)
+
+// ProfileType is the name of a runtime profile captured next to the CPU profile.
+type ProfileType string
+
+const (
+	HeapProfile      ProfileType = "heap"
+	AllocsProfile    ProfileType = "allocs"
+	GoroutineProfile ProfileType = "goroutine"
+	BlockProfile     ProfileType = "block"
+	MutexProfile     ProfileType = "mutex"
+)
+
+const (
+	defaultBlockProfileRate     = 1
+	defaultMutexProfileFraction = 1
+)
+
+var runtimeProfileTypes = []ProfileType{
+	HeapProfile,
+	AllocsProfile,
+	GoroutineProfile,
+	BlockProfile,
+	MutexProfile,
+}
+
+// ProfileTypesOption enables capturing the given runtime profiles for every run.
+func ProfileTypesOption(types ...ProfileType) ProfilerOption {
+	return func(p *Profiler) {
+		p.profileTypes = append(p.profileTypes, types...)
+	}
+}
+
+// BlockProfileRateOption overrides the rate passed to runtime.SetBlockProfileRate while a run is active.
+func BlockProfileRateOption(rate int) ProfilerOption {
+	return func(p *Profiler) {
+		p.blockProfileRate = rate
+	}
+}
+
+// BaseBlockProfileRateOption sets the block profile rate the application uses outside runs.
+// runtime doesn't report the current block profile rate, so it can't be saved like the mutex profile fraction and this rate ( 0 by default ) is restored when a run stops.
+func BaseBlockProfileRateOption(rate int) ProfilerOption {
+	return func(p *Profiler) {
+		p.baseBlockProfileRate = rate
+	}
+}
+
+// MutexProfileFractionOption overrides the fraction passed to runtime.SetMutexProfileFraction while a run is active.
+func MutexProfileFractionOption(fraction int) ProfilerOption {
+	return func(p *Profiler) {
+		p.mutexProfileFraction = fraction
+	}
+}
+
+func profileFileName(typ ProfileType, currentTime string) string {
+	return fmt.Sprintf("%s_%s.pprof", typ, currentTime)
+}
+
+// isDelta reports whether the profile is cumulative, so that only the samples
+// recorded between Start and Stop are kept.
+func (t ProfileType) isDelta() bool {
+	return t != GoroutineProfile
+}
+
+type runtimeProfile struct {
+	typ  ProfileType
+	base *profile.Profile
+}
+
+func lookupProfile(typ ProfileType) (*profile.Profile, error) {
+	if typ == HeapProfile {
+		runtime.GC()
+	}
+	var buf bytes.Buffer
+	if err := pprof.Lookup(string(typ)).WriteTo(&buf, 0); err != nil {
+		return nil, fmt.Errorf("failed to write %s profile: %w", typ, err)
+	}
+	prof, err := profile.Parse(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s profile: %w", typ, err)
+	}
+	return prof, nil
+}
+
+func (p *Profiler) startRuntimeProfiles() error {
+	p.runtimeProfiles = p.runtimeProfiles[:0]
+	for _, typ := range p.profileTypes {
+		// the profile is recorded before its rate is enabled, so that stopRuntimeProfiles resets exactly the enabled rates on rollback.
+		rp := &runtimeProfile{typ: typ}
+		p.runtimeProfiles = append(p.runtimeProfiles, rp)
+		switch typ {
+		case BlockProfile:
+			runtime.SetBlockProfileRate(p.blockProfileRate)
+		case MutexProfile:
+			p.prevMutexProfileFraction = runtime.SetMutexProfileFraction(p.mutexProfileFraction)
+		}
+		if typ.isDelta() {
+			base, err := lookupProfile(typ)
+			if err != nil {
+				return err
+			}
+			rp.base = base
+		}
+	}
+	return nil
+}
+
+func (p *Profiler) stopRuntimeProfiles() {
+	for _, rp := range p.runtimeProfiles {
+		path := filepath.Join(p.baseDir, profileFileName(rp.typ, p.currentTime))
+		if err := rp.write(path); err != nil {
+			log.Printf("failed to write %s profile: %+v", rp.typ, err)
+		}
+		switch rp.typ {
+		case BlockProfile:
+			runtime.SetBlockProfileRate(p.baseBlockProfileRate)
+		case MutexProfile:
+			runtime.SetMutexProfileFraction(p.prevMutexProfileFraction)
+		}
+	}
+	p.runtimeProfiles = p.runtimeProfiles[:0]
+}
+
+func (rp *runtimeProfile) write(path string) error {
+	prof, err := lookupProfile(rp.typ)
+	if err != nil {
+		return err
+	}
+	if rp.base != nil {
+		rp.base.Scale(-1)
+		delta, err := profile.Merge([]*profile.Profile{rp.base, prof})
+		if err != nil {
+			return fmt.Errorf("failed to compute delta of %s profile: %w", rp.typ, err)
+		}
+		prof = delta
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	defer f.Close()
+	if err := prof.Write(f); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return nil
+}
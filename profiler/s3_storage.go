@@ -0,0 +1,291 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3DefaultRegion   = "us-east-1"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// s3EmptyPayloadHash is the hash of the empty body of the requests other than Put.
+var s3EmptyPayloadHash = fmt.Sprintf("%x", sha256.Sum256(nil))
+
+// S3Config is the configuration of S3Storage.
+// Endpoint is the URL of the S3 compatible server such as https://s3.ap-northeast-1.amazonaws.com or http://localhost:9000 ( MinIO ).
+// The path of Endpoint is kept, so the server can be behind a reverse proxy such as http://proxy/minio .
+// Objects are stored as <Prefix><key> in Bucket. Requests are not signed if AccessKeyID is empty.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Client          *http.Client
+}
+
+// S3Storage stores files in a bucket of Amazon S3 or an S3 compatible server with path-style requests.
+type S3Storage struct {
+	cfg    S3Config
+	client *http.Client
+}
+
+func NewS3Storage(cfg S3Config) *S3Storage {
+	if cfg.Region == "" {
+		cfg.Region = s3DefaultRegion
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Storage{cfg: cfg, client: client}
+}
+
+// Put streams r without reading it into memory. The payload is not signed ( UNSIGNED-PAYLOAD ) because the hash needs the whole content.
+// S3 rejects the chunked body, so r is spooled to a temporary file unless the size is known ( a regular *os.File , *bytes.Reader , *bytes.Buffer or *strings.Reader ).
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader) error {
+	if !isSizedReader(r) {
+		f, err := spoolTempFile(r)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		r = f
+	}
+	res, err := s.do(ctx, http.MethodPut, s.cfg.Prefix+key, nil, r)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func isSizedReader(r io.Reader) bool {
+	switch r := r.(type) {
+	case *bytes.Reader, *bytes.Buffer, *strings.Reader:
+		return true
+	case *os.File:
+		info, err := r.Stat()
+		return err == nil && info.Mode().IsRegular()
+	}
+	return false
+}
+
+func spoolTempFile(r io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "s3-put-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to write temporary file %s: %w", f.Name(), err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to seek temporary file %s: %w", f.Name(), err)
+	}
+	return f, nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, s.cfg.Prefix+key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]string, error) {
+	var (
+		keys  []string
+		token string
+	)
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.cfg.Prefix+prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		res, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode objects of %s: %w", s.cfg.Bucket, err)
+		}
+		for _, content := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(content.Key, s.cfg.Prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, s.cfg.Prefix+key, nil, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// do sends a request for the object ( or the bucket if key is empty ). It returns an error unless the status is 2xx.
+// The body is sent only by Put.
+func (s *S3Storage) do(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Response, error) {
+	u, err := url.Parse(strings.TrimSuffix(s.cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint %s: %w", s.cfg.Endpoint, err)
+	}
+	u.Path = path.Join("/", u.Path, s.cfg.Bucket, key)
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = s3CanonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	payloadHash := s3EmptyPayloadHash
+	if body != nil {
+		payloadHash = s3UnsignedPayload
+		if f, ok := body.(*os.File); ok {
+			info, err := f.Stat()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get size of %s: %w", f.Name(), err)
+			}
+			offset, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get offset of %s: %w", f.Name(), err)
+			}
+			req.ContentLength = info.Size() - offset
+		}
+		// the body of zero length is sent chunked unless it is http.NoBody .
+		if req.ContentLength == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	s.sign(req, payloadHash, time.Now().UTC())
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s %s: %w", method, u.Path, err)
+	}
+	if res.StatusCode/100 == 2 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	msg, _ := io.ReadAll(res.Body)
+	if res.StatusCode == http.StatusNotFound && key != "" {
+		return nil, fmt.Errorf("failed to request %s %s: %w", method, u.Path, fs.ErrNotExist)
+	}
+	return nil, fmt.Errorf("failed to request %s %s: %s: %s", method, u.Path, res.Status, strings.TrimSpace(string(msg)))
+}
+
+// sign adds the headers of AWS Signature Version 4. payloadHash is the hex encoded hash of the body or UNSIGNED-PAYLOAD .
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	if s.cfg.AccessKeyID == "" {
+		return
+	}
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, req.Header.Get("X-Amz-Content-Sha256"), req.Header.Get("X-Amz-Date")),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format(s3DateFormat), s.cfg.Region)
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format(s3TimeFormat),
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+	key := []byte("AWS4" + s.cfg.SecretAccessKey)
+	for _, v := range []string{now.Format(s3DateFormat), s.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes s as URI encoding of AWS Signature Version 4.
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
@@ -0,0 +1,228 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	scoreEndpoint     = "/scores"
+	benchmarkArtifact = "benchmark"
+)
+
+func benchmarkOutputFileName(currentTime string) string {
+	return fmt.Sprintf("benchmark_%s.txt", currentTime)
+}
+
+// SetScore attaches the benchmark score and the output of the benchmarker to the running run, or to the latest stored run if Profiler is not running.
+// The score is recorded in the manifest and the output is written as benchmark_<time>.txt .
+func (p *Profiler) SetScore(score float64, output string) error {
+	p.stateMu.Lock()
+	state := p.state
+	if state == StateRunning {
+		defer p.stateMu.Unlock()
+		return p.applyScore(p.currentTime, p.manifest, score, output)
+	}
+	p.stateMu.Unlock()
+	if state == StateStarting || state == StateStopping {
+		return &StateError{Op: "set score of", State: state}
+	}
+	if err := p.createBaseDirIfNotExists(); err != nil {
+		return err
+	}
+	runs, err := listStoredRuns(p.baseDir)
+	if err != nil {
+		return err
+	}
+	for _, r := range runs {
+		path := filepath.Join(p.baseDir, manifestFileName(r.id))
+		manifest, err := readManifest(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.applyScore(r.id, manifest, score, output); err != nil {
+			return err
+		}
+		if err := manifest.write(path); err != nil {
+			return err
+		}
+		if p.storage != nil {
+			if err := p.pushRun(context.Background(), r.id); err != nil {
+				return err
+			}
+		}
+		p.setRunManifest(cpuProfileFileName(r.id), manifest)
+		return nil
+	}
+	return fmt.Errorf("failed to find a run to set score")
+}
+
+func (p *Profiler) applyScore(currentTime string, manifest *Manifest, score float64, output string) error {
+	manifest.Score = &score
+	if output == "" {
+		return nil
+	}
+	path := filepath.Join(p.baseDir, benchmarkOutputFileName(currentTime))
+	if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+		return fmt.Errorf("failed to write benchmark output %s: %w", path, err)
+	}
+	for _, artifact := range manifest.Artifacts {
+		if artifact.Name == benchmarkArtifact {
+			return nil
+		}
+	}
+	manifest.Artifacts = append(manifest.Artifacts, Artifact{Name: benchmarkArtifact, Path: path})
+	return nil
+}
+
+var scoreTmpl = newPageTemplate("score", `<!DOCTYPE html>
+<html>
+<head>
+<title>scores</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h2>scores</h2>
+{{ if .Scores }}
+<svg width="{{ .Width }}" height="{{ .Height }}" xmlns="http://www.w3.org/2000/svg">
+  <text x="4" y="{{ .Top }}" font-size="11">{{ .Max }}</text>
+  <text x="4" y="{{ .Bottom }}" font-size="11">{{ .Min }}</text>
+  <line x1="{{ .Left }}" y1="{{ .Bottom }}" x2="{{ .Right }}" y2="{{ .Bottom }}" stroke="#ccc"/>
+  <line x1="{{ .Left }}" y1="{{ .Top }}" x2="{{ .Left }}" y2="{{ .Bottom }}" stroke="#ccc"/>
+  <polyline points="{{ .Points }}" fill="none" stroke="#2a66d9" stroke-width="2"/>
+  {{- range .Scores }}
+  <a href="/{{ .Idx }}/"><circle cx="{{ .X }}" cy="{{ .Y }}" r="4" fill="#2a66d9"><title>#{{ .Idx }} {{ .Score }}</title></circle></a>
+  <text x="{{ .X }}" y="{{ $.LabelY }}" font-size="11" text-anchor="middle">{{ .Idx }}</text>
+  {{- end }}
+</svg>
+<table>
+<tr><th>#</th><th>time</th><th>score</th><th>commit</th><th>benchmark</th></tr>
+{{- range .Scores }}
+<tr>
+  <td><a href="/{{ .Idx }}/">{{ .Idx }}</a></td>
+  <td>{{ .Time }}</td>
+  <td>{{ .Score }}</td>
+  <td>{{ .Commit }}</td>
+  <td>{{ if .Output }}<a href="/{{ .Idx }}/artifacts/{{ .Output }}">output</a>{{ end }}</td>
+</tr>
+{{- end }}
+</table>
+{{ else }}
+<p>no score. POST /debug/profiler/score or call Profiler.SetScore to record it.</p>
+{{ end }}
+</body>
+</html>`)
+
+const (
+	scoreChartWidth   = 800
+	scoreChartHeight  = 300
+	scoreChartPadding = 40
+)
+
+type scorePoint struct {
+	Idx    int
+	Time   string
+	Score  float64
+	Commit string
+	Output string
+	X      float64
+	Y      float64
+}
+
+// scoreHandler charts the score of every loaded run.
+type scoreHandler struct {
+	profiler *Profiler
+}
+
+func (h *scoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	runs := h.profiler.loadedRuns()
+	// the chart is drawn in the order of time because imported runs may be older than the loaded ones.
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].time.Before(runs[j].time)
+	})
+	var points []*scorePoint
+	h.profiler.runsMu.RLock()
+	for _, run := range runs {
+		m := run.manifest
+		if m == nil || m.Score == nil {
+			continue
+		}
+		point := &scorePoint{
+			Idx:    run.idx,
+			Time:   run.time.Format("2006-01-02 15:04:05"),
+			Score:  *m.Score,
+			Commit: m.GitCommit,
+		}
+		if len(point.Commit) > 7 {
+			point.Commit = point.Commit[:7]
+		}
+		for _, artifact := range m.Artifacts {
+			if artifact.Name == benchmarkArtifact {
+				point.Output = artifact.Name
+			}
+		}
+		points = append(points, point)
+	}
+	h.profiler.runsMu.RUnlock()
+
+	minScore, maxScore := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		minScore = math.Min(minScore, point.Score)
+		maxScore = math.Max(maxScore, point.Score)
+	}
+	if minScore == maxScore {
+		minScore, maxScore = minScore-1, maxScore+1
+	}
+	var (
+		left   = float64(scoreChartPadding)
+		right  = float64(scoreChartWidth - scoreChartPadding)
+		top    = float64(scoreChartPadding / 2)
+		bottom = float64(scoreChartHeight - scoreChartPadding)
+		coords []string
+	)
+	for idx, point := range points {
+		point.X = left
+		if len(points) > 1 {
+			point.X = left + (right-left)*float64(idx)/float64(len(points)-1)
+		}
+		point.Y = bottom - (bottom-top)*(point.Score-minScore)/(maxScore-minScore)
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", point.X, point.Y))
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if err := scoreTmpl.Execute(w, struct {
+		Scores                   []*scorePoint
+		Points                   string
+		Min, Max                 float64
+		Width, Height            int
+		Left, Right, Top, Bottom float64
+		LabelY                   float64
+	}{
+		Scores: points,
+		Points: strings.Join(coords, " "),
+		Min:    minScore,
+		Max:    maxScore,
+		Width:  scoreChartWidth,
+		Height: scoreChartHeight,
+		Left:   left,
+		Right:  right,
+		Top:    top,
+		Bottom: bottom,
+		LabelY: bottom + 16,
+	}); err != nil {
+		log.Printf("failed to render score page: %+v", err)
+	}
+}
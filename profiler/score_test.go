@@ -0,0 +1,112 @@
+package profiler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestProfilerSetScore(t *testing.T) {
+	p := profilertools.NewProfiler(t.TempDir())
+	if err := p.SetScore(100, ""); err == nil {
+		t.Fatal("expected error without runs")
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetScore(1000, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := p.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := runs[0].Manifest
+	if m.Score == nil || *m.Score != 1000 {
+		t.Fatalf("unexpected score: %v", m.Score)
+	}
+	if len(m.Artifacts) != 1 || m.Artifacts[0].Name != "benchmark" {
+		t.Fatalf("benchmark output is not recorded: %+v", m.Artifacts)
+	}
+	if b, err := os.ReadFile(m.Artifacts[0].Path); err != nil || string(b) != "pass" {
+		t.Fatalf("unexpected benchmark output: %q %v", b, err)
+	}
+
+	// the score is attached to the latest run after Stop.
+	if err := p.SetScore(2000, "fail"); err != nil {
+		t.Fatal(err)
+	}
+	runs, err = p.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = runs[0].Manifest
+	if *m.Score != 2000 || len(m.Artifacts) != 1 {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+	if b, err := os.ReadFile(m.Artifacts[0].Path); err != nil || string(b) != "fail" {
+		t.Fatalf("unexpected benchmark output: %q %v", b, err)
+	}
+	if err := p.StartContinuous(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopContinuous()
+	if err := p.SetScore(3000, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeScoreRun(t *testing.T, dir, id string, score float64) {
+	t.Helper()
+	writeTestRuns(t, dir, id)
+	startTime, err := time.ParseInLocation("2006_01_02_15_04_05", id, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(&profilertools.Manifest{StartTime: startTime, Score: &score})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest_"+id+".json"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScoreOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeScoreRun(t, dir, "2020_01_01_00_02_00", 200)
+	writeScoreRun(t, dir, "2020_01_01_00_03_00", 300)
+	p := profilertools.NewProfiler(dir)
+	h, err := profilertools.ServeHandler(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the imported run is older than the loaded runs but mounted last.
+	src := t.TempDir()
+	writeScoreRun(t, src, "2020_01_01_00_01_00", 100)
+	var bundle bytes.Buffer
+	if err := profilertools.NewProfiler(src).ExportRun("2020_01_01_00_01_00", &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ImportRun(&bundle); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scores", nil))
+	body := rec.Body.String()
+	first, second, third := strings.Index(body, `<td>100</td>`), strings.Index(body, `<td>200</td>`), strings.Index(body, `<td>300</td>`)
+	if first < 0 || first > second || second > third {
+		t.Fatalf("scores are not ordered by time:\n%s", body)
+	}
+}
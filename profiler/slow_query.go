@@ -11,12 +11,12 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/goccy/echo-tools/gist"
-	"github.com/goccy/echo-tools/notifier"
+	"github.com/goccy/echo-tools/slowlog"
 	"github.com/labstack/echo/v4"
 )
 
@@ -33,8 +33,22 @@ type MySQLSlowQueryLogProfiler struct {
 	botName              string
 	discordWebhookURL    string
 	githubToken          string
+	storage              Storage
+	auth                 Credential
+	analyzer             SlowQueryAnalyzer
+	artifacts            []Artifact
 }
 
+// SlowQueryAnalyzer is the tool which analyzes the slow query log.
+type SlowQueryAnalyzer string
+
+const (
+	// PTQueryDigestAnalyzer runs pt-query-digest. It is the default analyzer.
+	PTQueryDigestAnalyzer SlowQueryAnalyzer = "pt-query-digest"
+	// NativeSlowQueryAnalyzer analyzes the slow query log in Go without Perl and pt-query-digest.
+	NativeSlowQueryAnalyzer SlowQueryAnalyzer = "native"
+)
+
 type MySQLSlowQueryLogProfilerOption func(*MySQLSlowQueryLogProfiler)
 
 func MySQLSlowQueryLogDiscordNotifierOption(botName, webhookURL, githubToken string) MySQLSlowQueryLogProfilerOption {
@@ -45,6 +59,28 @@ func MySQLSlowQueryLogDiscordNotifierOption(botName, webhookURL, githubToken str
 	}
 }
 
+// MySQLSlowQueryLogStorageOption stores the output of pt-query-digest in storage as slow-query-log/<file name> on the host which analyzes the slow query log.
+// Profiler with StorageOption downloads it even if it runs on another host.
+func MySQLSlowQueryLogStorageOption(storage Storage) MySQLSlowQueryLogProfilerOption {
+	return func(p *MySQLSlowQueryLogProfiler) {
+		p.storage = storage
+	}
+}
+
+// MySQLSlowQueryLogAuthOption protects POST /debug/slowQueryLog with cred. Stop sends the request with cred.
+func MySQLSlowQueryLogAuthOption(cred Credential) MySQLSlowQueryLogProfilerOption {
+	return func(p *MySQLSlowQueryLogProfiler) {
+		p.auth = cred
+	}
+}
+
+// MySQLSlowQueryLogAnalyzerOption selects the analyzer of the slow query log.
+func MySQLSlowQueryLogAnalyzerOption(analyzer SlowQueryAnalyzer) MySQLSlowQueryLogProfilerOption {
+	return func(p *MySQLSlowQueryLogProfiler) {
+		p.analyzer = analyzer
+	}
+}
+
 func NewMySQLSlowQueryLogProfiler(e *echo.Echo, hostAddr string, db *sql.DB, opts ...MySQLSlowQueryLogProfilerOption) *MySQLSlowQueryLogProfiler {
 	slowQueryLogFileName := fmt.Sprintf(
 		"%s_slow_query.log",
@@ -56,19 +92,28 @@ func NewMySQLSlowQueryLogProfiler(e *echo.Echo, hostAddr string, db *sql.DB, opt
 		db:                   db,
 		slowQueryLogFileName: slowQueryLogFileName,
 	}
-	e.POST(slowQueryLogEndpoint, echo.WrapHandler(&MySQLSlowQueryLogHandler{}))
 	for _, opt := range opts {
 		opt(p)
 	}
+	e.POST(slowQueryLogEndpoint, echo.WrapHandler(p.auth.handler(&MySQLSlowQueryLogHandler{storage: p.storage})))
 	return p
 }
 
+func (p *MySQLSlowQueryLogProfiler) Name() string {
+	return "slow-query-log"
+}
+
 func (p *MySQLSlowQueryLogProfiler) Start() error {
+	return p.StartContext(context.Background())
+}
+
+func (p *MySQLSlowQueryLogProfiler) StartContext(ctx context.Context) error {
 	log.Printf("slow query log: %s", p.slowQueryLogFileName)
-	if _, err := p.db.Exec("SET GLOBAL slow_query_log = 1"); err != nil {
+	if _, err := p.db.ExecContext(ctx, "SET GLOBAL slow_query_log = 1"); err != nil {
 		return fmt.Errorf("failed to enable slow_query_log: %w", err)
 	}
-	if _, err := p.db.Exec(
+	if _, err := p.db.ExecContext(
+		ctx,
 		fmt.Sprintf(
 			"SET GLOBAL slow_query_log_file = `%s`",
 			p.slowQueryLogFileName,
@@ -76,7 +121,7 @@ func (p *MySQLSlowQueryLogProfiler) Start() error {
 	); err != nil {
 		return fmt.Errorf("failed to set slow query log file: %w", err)
 	}
-	if _, err := p.db.Exec("SET GLOBAL long_query_time = 0"); err != nil {
+	if _, err := p.db.ExecContext(ctx, "SET GLOBAL long_query_time = 0"); err != nil {
 		return fmt.Errorf("failed to set long_query_time: %w", err)
 	}
 	return nil
@@ -89,14 +134,30 @@ func (p *MySQLSlowQueryLogProfiler) requestURL() string {
 
 type MySQLSlowQueryLogRequest struct {
 	FileName          string `json:"filename"`
+	Title             string `json:"title"`
+	Analyzer          string `json:"analyzer"`
 	BotName           string `json:"botName"`
 	GitHubToken       string `json:"githubToken"`
 	DiscordWebhookURL string `json:"discordWebhookURL"`
 }
 
+type MySQLSlowQueryLogResponse struct {
+	Artifacts []Artifact `json:"artifacts"`
+}
+
 func (p *MySQLSlowQueryLogProfiler) Stop() error {
+	return p.StopContext(context.Background())
+}
+
+func (p *MySQLSlowQueryLogProfiler) StopContext(ctx context.Context) error {
+	var title string
+	if run, ok := RunFromContext(ctx); ok {
+		title = run.Title()
+	}
 	b, err := json.Marshal(&MySQLSlowQueryLogRequest{
 		FileName:          p.slowQueryLogFileName,
+		Title:             title,
+		Analyzer:          string(p.analyzer),
 		BotName:           p.botName,
 		GitHubToken:       p.githubToken,
 		DiscordWebhookURL: p.discordWebhookURL,
@@ -105,70 +166,121 @@ func (p *MySQLSlowQueryLogProfiler) Stop() error {
 		return fmt.Errorf("failed to encode slow query log: %w", err)
 	}
 	url := p.requestURL()
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(b))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(b))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/json")
+	p.auth.setTo(req)
 	httpClient := new(http.Client)
-	if _, err := httpClient.Do(req); err != nil {
+	resp, err := httpClient.Do(req)
+	if err != nil {
 		return fmt.Errorf("failed to post %s: %w", url, err)
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		buf, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to analyze slow query log: %s", string(buf))
+	}
+	var res MySQLSlowQueryLogResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("failed to decode slow query log response: %w", err)
+	}
+	p.artifacts = res.Artifacts
 	return nil
 }
 
-type MySQLSlowQueryLogHandler struct{}
+func (p *MySQLSlowQueryLogProfiler) Artifacts() []Artifact {
+	return p.artifacts
+}
+
+type MySQLSlowQueryLogHandler struct {
+	storage Storage
+}
 
 func (h *MySQLSlowQueryLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if err := h.handle(r.Context(), r.Body); err != nil {
+	artifacts, err := h.handle(r.Context(), r.Body)
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintln(w, err.Error())
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&MySQLSlowQueryLogResponse{Artifacts: artifacts})
 }
 
 var (
-	queryDigestCommandTmpl = `sudo pt-query-digest /var/lib/mysql/%s > %s`
+	queryDigestCommandTmpl      = `sudo pt-query-digest /var/lib/mysql/%s > %s`
+	slowQueryLogReadCommandTmpl = `sudo cat /var/lib/mysql/%s`
 )
 
-func (h *MySQLSlowQueryLogHandler) handle(ctx context.Context, body io.Reader) error {
+func (h *MySQLSlowQueryLogHandler) handle(ctx context.Context, body io.Reader) ([]Artifact, error) {
 	var req MySQLSlowQueryLogRequest
 	if err := json.NewDecoder(body).Decode(&req); err != nil {
-		return fmt.Errorf("failed to decode slow query log request: %w", err)
+		return nil, fmt.Errorf("failed to decode slow query log request: %w", err)
 	}
 	if req.FileName == "" {
-		return fmt.Errorf("failed to find slow-query-log filename")
+		return nil, fmt.Errorf("failed to find slow-query-log filename")
 	}
 	tempDir := os.TempDir()
 	digestFile := filepath.Join(tempDir, fmt.Sprintf("digest_%s", req.FileName))
-	cmd := exec.Command(
-		"sh", "-c", fmt.Sprintf(queryDigestCommandTmpl, req.FileName, digestFile),
-	)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to exec pt-query-digest: %s: %w", string(out), err)
+	var artifact Artifact
+	switch SlowQueryAnalyzer(req.Analyzer) {
+	case "", PTQueryDigestAnalyzer:
+		cmd := exec.Command(
+			"sh", "-c", fmt.Sprintf(queryDigestCommandTmpl, req.FileName, digestFile),
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("failed to exec pt-query-digest: %s: %w", string(out), err)
+		}
+		artifact = Artifact{Name: "pt-query-digest", Path: digestFile}
+	case NativeSlowQueryAnalyzer:
+		if err := writeQueryDigest(req.FileName, digestFile); err != nil {
+			return nil, err
+		}
+		artifact = Artifact{Name: "query-digest", Path: digestFile}
+	default:
+		return nil, fmt.Errorf("failed to find slow query log analyzer: %s", req.Analyzer)
+	}
+	if h.storage != nil {
+		key := path.Join("slow-query-log", filepath.Base(digestFile))
+		if err := putFile(ctx, h.storage, key, digestFile); err != nil {
+			return []Artifact{artifact}, err
+		}
+		artifact.Key = key
 	}
 	if req.GitHubToken == "" || req.DiscordWebhookURL == "" {
 		log.Println("github token or discord webhook url is not found")
-		return nil
+		return []Artifact{artifact}, nil
 	}
-	client, err := gist.NewClient(ctx, req.GitHubToken)
-	if err != nil {
-		return fmt.Errorf("failed to create gist client: %w", err)
+	title := reportTitle("slow-query-log digest", req.Title)
+	gistTitle := req.FileName
+	if req.Title != "" {
+		gistTitle = title
 	}
-	url, err := client.UploadFile(ctx, req.FileName, digestFile)
-	if err != nil {
-		return fmt.Errorf("failed to upload slow-query-log: %w", err)
+	url, err := shareReport(ctx, gistTitle, title, digestFile, req.BotName, req.DiscordWebhookURL, req.GitHubToken)
+	artifact.URL = url
+	return []Artifact{artifact}, err
+}
+
+// writeQueryDigest analyzes the slow query log by the slowlog package and writes the report to digestFile.
+func writeQueryDigest(fileName, digestFile string) error {
+	var digest *slowlog.Digest
+	if err := readPrivilegedFile(slowQueryLogReadCommandTmpl, fileName, func(r io.Reader) error {
+		parsed, err := slowlog.ParseDigest(r)
+		digest = parsed
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to parse slow query log: %w", err)
 	}
-	botName := req.BotName
-	if botName == "" {
-		botName = "bot"
+	f, err := os.Create(digestFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", digestFile, err)
 	}
-	discordClient := notifier.NewDiscordClient(req.DiscordWebhookURL)
-	if err := discordClient.Post(&notifier.DiscordMessage{
-		Username: botName,
-		Content:  fmt.Sprintf("slow-query-log digest: %s", url),
-	}); err != nil {
-		return fmt.Errorf("failed to post message to discord: %w", err)
+	defer f.Close()
+	if err := digest.WriteReport(f, 0); err != nil {
+		return fmt.Errorf("failed to write query digest: %w", err)
 	}
 	return nil
 }
@@ -3,3 +3,7 @@ package profiler
 func ReplaceQueryDigestCommandTemplate() {
 	queryDigestCommandTmpl = `echo "test %s" > %s`
 }
+
+func ReplaceSlowQueryLogReadCommandTemplate(dir string) {
+	slowQueryLogReadCommandTmpl = "cat " + dir + "/%s"
+}
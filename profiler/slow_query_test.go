@@ -2,7 +2,12 @@ package profiler_test
 
 import (
 	"database/sql"
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -32,3 +37,49 @@ func TestSlowQueryLog(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestSlowQueryLogNativeAnalyzer(t *testing.T) {
+	dir := t.TempDir()
+	log := `# Time: 2024-01-02T03:04:05.000000Z
+# User@Host: isucon[isucon] @ localhost []  Id:     8
+# Query_time: 0.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000
+use isuports;
+SET timestamp=1704164645;
+SELECT * FROM users WHERE id = 10;
+`
+	if err := os.WriteFile(filepath.Join(dir, "native_slow_query.log"), []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	profilertools.ReplaceSlowQueryLogReadCommandTemplate(dir)
+
+	e := echo.New()
+	server := httptest.NewServer(e)
+	defer server.Close()
+	profilertools.NewMySQLSlowQueryLogProfiler(e, server.URL, nil, profilertools.MySQLSlowQueryLogAnalyzerOption(profilertools.NativeSlowQueryAnalyzer))
+	res, err := http.Post(
+		server.URL+"/debug/slowQueryLog",
+		"application/json",
+		strings.NewReader(`{"filename":"native_slow_query.log","analyzer":"native"}`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", res.StatusCode)
+	}
+	var v profilertools.MySQLSlowQueryLogResponse
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Artifacts) != 1 || v.Artifacts[0].Name != "query-digest" {
+		t.Fatalf("unexpected artifacts: %+v", v.Artifacts)
+	}
+	b, err := os.ReadFile(v.Artifacts[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "# Overall: 1 total, 1 unique") || !strings.Contains(string(b), "SELECT users") {
+		t.Fatalf("unexpected digest:\n%s", b)
+	}
+}
@@ -0,0 +1,76 @@
+package profiler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// State is the lifecycle state of Profiler.
+type State string
+
+const (
+	StateIdle     State = "idle"
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	StateStopping State = "stopping"
+	// StateContinuous is the state between StartContinuous and StopContinuous.
+	StateContinuous State = "continuous"
+)
+
+// ErrInvalidState is returned when Start or Stop is called in a state that does not allow it.
+var ErrInvalidState = errors.New("invalid profiler state")
+
+type StateError struct {
+	Op    string
+	State State
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("failed to %s profiler: profiler is %s", e.Op, e.State)
+}
+
+func (e *StateError) Unwrap() error {
+	return ErrInvalidState
+}
+
+// Status is the current state of Profiler. Run is set while a run started by Start is in progress.
+type Status struct {
+	State State      `json:"state"`
+	Run   *RunStatus `json:"run,omitempty"`
+}
+
+type RunStatus struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	StartTime time.Time         `json:"startTime"`
+	Profile   string            `json:"profile"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+func (p *Profiler) Status() Status {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	status := Status{State: p.state}
+	if p.state == StateIdle || p.continuous != nil || p.manifest == nil || p.pprofFile == nil {
+		return status
+	}
+	status.Run = newRunStatus(p.currentTime, p.pprofFile.Name(), p.manifest)
+	return status
+}
+
+func (p *Profiler) transition(op string, from, to State) error {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	if p.state != from {
+		return &StateError{Op: op, State: p.state}
+	}
+	p.state = to
+	return nil
+}
+
+func (p *Profiler) setState(state State) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.state = state
+}
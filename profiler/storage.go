@@ -0,0 +1,308 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Storage stores the files of runs and the reports of SubProfilers so that they survive the teardown of the instance.
+// Keys are slash separated names such as pprof_2006_01_02_15_04_05.pprof or access-log/alp.log.2006_01_02_15_04_05 .
+// Get returns an error wrapping fs.ErrNotExist if the key does not exist.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// LocalStorage stores files in a directory of the local filesystem.
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: dir}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned == "/" || cleaned[1:] != key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", dst, err)
+	}
+	// write to a temporary file first not to break dst if r reads it.
+	f, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", dst, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to change mode of %s: %w", dst, err)
+	}
+	if err := os.Rename(f.Name(), dst); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to rename to %s: %w", dst, err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	src, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	return f, nil
+}
+
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	if err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.dir, err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", dst, err)
+	}
+	return nil
+}
+
+// StorageOption stores every run in storage on Stop and ImportRun in addition to baseDir.
+// ListenAndServe and PullRuns download the runs in storage which are not in baseDir, and retention deletes them from storage as well.
+func StorageOption(storage Storage) ProfilerOption {
+	return func(p *Profiler) {
+		p.storage = storage
+	}
+}
+
+// putFile stores the file at path as key.
+func putFile(ctx context.Context, storage Storage, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	if err := storage.Put(ctx, key, f); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return nil
+}
+
+// getFile writes the content of key to path.
+func getFile(ctx context.Context, storage Storage, key, path string) error {
+	r, err := storage.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	defer r.Close()
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return nil
+}
+
+// storeArtifacts copies the reports out of baseDir into baseDir to store them with the run.
+// The reports on other hosts are downloaded from storage if the SubProfiler stored them.
+func (p *Profiler) storeArtifacts(ctx context.Context, artifacts []Artifact) ([]Artifact, error) {
+	var errs MultiError
+	for idx, artifact := range artifacts {
+		if artifact.Path == "" && artifact.Key == "" {
+			continue
+		}
+		if artifact.Path != "" && filepath.Dir(artifact.Path) == filepath.Clean(p.baseDir) {
+			continue
+		}
+		dst := filepath.Join(p.baseDir, bundleArtifactFileName(p.currentTime, artifact.Name))
+		var err error
+		if _, statErr := os.Stat(artifact.Path); artifact.Path != "" && statErr == nil {
+			err = copyFile(artifact.Path, dst)
+		} else if artifact.Key != "" {
+			err = getFile(ctx, p.storage, artifact.Key, dst)
+		} else {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		artifacts[idx].Path = dst
+	}
+	return artifacts, errs.errorOrNil()
+}
+
+func copyFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer r.Close()
+	w, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", dst, err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return w.Close()
+}
+
+// pushRun stores every file of the run in storage.
+func (p *Profiler) pushRun(ctx context.Context, id string) error {
+	runs, err := listStoredRuns(p.baseDir)
+	if err != nil {
+		return err
+	}
+	var errs MultiError
+	for _, r := range runs {
+		if r.id != id {
+			continue
+		}
+		for _, file := range r.files {
+			if err := putFile(ctx, p.storage, filepath.Base(file), file); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// PullRuns downloads the runs in Storage specified by StorageOption which are not in baseDir.
+func (p *Profiler) PullRuns(ctx context.Context) error {
+	if p.storage == nil {
+		return nil
+	}
+	if err := p.createBaseDirIfNotExists(); err != nil {
+		return err
+	}
+	keys, err := p.storage.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list runs in storage: %w", err)
+	}
+	var (
+		errs      MultiError
+		manifests []string
+	)
+	for _, key := range keys {
+		matched := runFileRe.FindStringSubmatch(key)
+		if matched == nil {
+			continue
+		}
+		if _, exists := runFilePrefixes[matched[1]]; !exists {
+			continue
+		}
+		dst := filepath.Join(p.baseDir, key)
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := getFile(ctx, p.storage, key, dst); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if matched[1] == "manifest" {
+			manifests = append(manifests, dst)
+		}
+	}
+	// the paths in the manifest are on the host which stored the run.
+	for _, path := range manifests {
+		manifest, err := readManifest(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		relocateManifest(manifest, p.baseDir)
+		if err := manifest.write(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// relocateManifest replaces the directory of the paths in the manifest with dir.
+func relocateManifest(m *Manifest, dir string) {
+	for typ, path := range m.Profiles {
+		m.Profiles[typ] = filepath.Join(dir, filepath.Base(path))
+	}
+	for idx, artifact := range m.Artifacts {
+		if artifact.Path != "" {
+			m.Artifacts[idx].Path = filepath.Join(dir, filepath.Base(artifact.Path))
+		}
+	}
+}
+
+// deleteStoredRun deletes the files of the run from storage.
+func (p *Profiler) deleteStoredRun(ctx context.Context, r *storedRun) error {
+	for _, file := range r.files {
+		if err := p.storage.Delete(ctx, filepath.Base(file)); err != nil {
+			return fmt.Errorf("failed to delete %s from storage: %w", filepath.Base(file), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package profiler_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+// fakeS3Server is an in-memory S3 compatible server like MinIO which supports path-style requests.
+// The server is served under base like behind a reverse proxy.
+type fakeS3Server struct {
+	base    string
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeS3Server(t *testing.T, base, bucket string) *httptest.Server {
+	s := &fakeS3Server{base: base, bucket: bucket, objects: map[string][]byte{}}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	hash := sha256.Sum256(body)
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if (r.Method != http.MethodPut || payloadHash != "UNSIGNED-PAYLOAD") && payloadHash != hex.EncodeToString(hash[:]) {
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && r.ContentLength != int64(len(body)) {
+		http.Error(w, "MissingContentLength", http.StatusLengthRequired)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, s.base+"/") {
+		http.Error(w, "NotFound", http.StatusNotFound)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, s.base+"/")
+	if path != s.bucket && !strings.HasPrefix(path, s.bucket+"/") {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(path, s.bucket), "/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r)
+	case r.Method == http.MethodPut:
+		s.objects[key] = body
+	case r.Method == http.MethodGet:
+		object, exists := s.objects[key]
+		if !exists {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+// list returns two objects at most per page to test the continuation.
+func (s *fakeS3Server) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key > r.URL.Query().Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	type content struct {
+		Key string `xml:"Key"`
+	}
+	var result struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}
+	for idx, key := range keys {
+		if idx == 2 {
+			result.IsTruncated = true
+			result.NextContinuationToken = keys[idx-1]
+			break
+		}
+		result.Contents = append(result.Contents, content{Key: key})
+	}
+	xml.NewEncoder(w).Encode(&result)
+}
+
+func newTestS3Storage(t *testing.T, base string) *profilertools.S3Storage {
+	server := newFakeS3Server(t, base, "profiles")
+	return profilertools.NewS3Storage(profilertools.S3Config{
+		Endpoint:        server.URL + base,
+		Bucket:          "profiles",
+		Prefix:          "isucon/",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio123",
+	})
+}
+
+func TestStorage(t *testing.T) {
+	for name, storage := range map[string]profilertools.Storage{
+		"local":        profilertools.NewLocalStorage(t.TempDir()),
+		"s3":           newTestS3Storage(t, ""),
+		"s3 with path": newTestS3Storage(t, "/minio"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, key := range []string{"pprof_a.pprof", "manifest_a.json", "access-log/alp log"} {
+				if err := storage.Put(ctx, key, strings.NewReader(key)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r, err := storage.Get(ctx, "access-log/alp log")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || string(b) != "access-log/alp log" {
+				t.Fatalf("unexpected content: %q %v", b, err)
+			}
+			keys, err := storage.List(ctx, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(keys, ",") != "access-log/alp log,manifest_a.json,pprof_a.pprof" {
+				t.Fatalf("unexpected keys: %v", keys)
+			}
+			keys, err = storage.List(ctx, "access-log/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 1 {
+				t.Fatalf("unexpected keys: %v", keys)
+			}
+			if err := storage.Delete(ctx, "pprof_a.pprof"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := storage.Get(ctx, "pprof_a.pprof"); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("expected not exist error but got %v", err)
+			}
+		})
+	}
+}
+
+func TestS3StoragePutUnsized(t *testing.T) {
+	// the fake server rejects the chunked body like S3.
+	storage := newTestS3Storage(t, "")
+	ctx := context.Background()
+	for key, content := range map[string]string{"unsized": "profile", "empty": ""} {
+		// io.MultiReader hides the size of the content.
+		if err := storage.Put(ctx, key, io.MultiReader(strings.NewReader(content))); err != nil {
+			t.Fatal(err)
+		}
+		rc, err := storage.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(b) != content {
+			t.Fatalf("unexpected content of %s: %q %v", key, b, err)
+		}
+	}
+}
+
+func TestProfilerStorage(t *testing.T) {
+	storage := newTestS3Storage(t, "")
+	src := profilertools.NewProfiler(t.TempDir(), profilertools.StorageOption(storage))
+	src.AddProfiler(&reportProfiler{path: filepath.Join(t.TempDir(), "alp.log")})
+	if err := src.Start(profilertools.RunNameOption("stored")); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := src.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := runs[0].ID
+	keys, err := storage.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := map[string]bool{}
+	for _, key := range keys {
+		stored[key] = true
+	}
+	for _, key := range []string{"pprof_" + id + ".pprof", "manifest_" + id + ".json", "artifact_" + id + "_alp.txt"} {
+		if !stored[key] {
+			t.Fatalf("%s is not stored: %v", key, keys)
+		}
+	}
+
+	// another instance reads the run from storage.
+	dir := t.TempDir()
+	dst := profilertools.NewProfiler(dir, profilertools.StorageOption(storage))
+	if err := dst.PullRuns(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	runs, err = dst.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != id || runs[0].Manifest == nil || runs[0].Manifest.Name != "stored" {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+	var found bool
+	for _, artifact := range runs[0].Manifest.Artifacts {
+		if artifact.Name != "alp" {
+			continue
+		}
+		found = true
+		if filepath.Dir(artifact.Path) != dir {
+			t.Fatalf("unexpected artifact path: %s", artifact.Path)
+		}
+		if b, err := os.ReadFile(artifact.Path); err != nil || string(b) != "| COUNT | METHOD | URI |" {
+			t.Fatalf("unexpected artifact: %q %v", b, err)
+		}
+	}
+	if !found {
+		t.Fatalf("alp is not recorded: %+v", runs[0].Manifest.Artifacts)
+	}
+}
@@ -0,0 +1,132 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NamedSubProfiler is implemented by SubProfilers to be named in errors and logs.
+type NamedSubProfiler interface {
+	Name() string
+}
+
+// ContextSubProfiler is implemented by SubProfilers that can abort Start and Stop by context.
+// Otherwise, Profiler stops waiting for them when the context is done.
+type ContextSubProfiler interface {
+	StartContext(context.Context) error
+	StopContext(context.Context) error
+}
+
+// SubProfilerError is an error returned by the SubProfiler named Name.
+type SubProfilerError struct {
+	Name string
+	Err  error
+}
+
+func (e *SubProfilerError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, e.Err)
+}
+
+func (e *SubProfilerError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is a list of errors occurred at the same time such as failures of several SubProfilers.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e MultiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e MultiError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorOrNil returns nil if errs is empty.
+func (e MultiError) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func subProfilerName(idx int, sub SubProfiler) string {
+	if named, ok := sub.(NamedSubProfiler); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("profiler%d(%T)", idx, sub)
+}
+
+func startSubProfiler(ctx context.Context, idx int, sub SubProfiler) error {
+	var err error
+	if c, ok := sub.(ContextSubProfiler); ok {
+		err = c.StartContext(ctx)
+	} else {
+		// if ctx is done first, the rollback doesn't stop sub, so it is stopped once the late Start succeeds.
+		err = waitContext(ctx, sub.Start, func(err error) {
+			if err == nil {
+				sub.Stop()
+			}
+		})
+	}
+	if err != nil {
+		return &SubProfilerError{Name: subProfilerName(idx, sub), Err: err}
+	}
+	return nil
+}
+
+func stopSubProfiler(ctx context.Context, idx int, sub SubProfiler) error {
+	var err error
+	if c, ok := sub.(ContextSubProfiler); ok {
+		err = c.StopContext(ctx)
+	} else {
+		err = waitContext(ctx, sub.Stop, nil)
+	}
+	if err != nil {
+		return &SubProfilerError{Name: subProfilerName(idx, sub), Err: err}
+	}
+	return nil
+}
+
+// waitContext runs f and returns the error of ctx if it is done before f returns.
+// In that case, late is called with the error of f after f returns if it isn't nil.
+func waitContext(ctx context.Context, f func() error, late func(error)) error {
+	if ctx.Done() == nil {
+		return f()
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- f()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		if late != nil {
+			go func() {
+				late(<-errCh)
+			}()
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,179 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/echo-tools/gist"
+	"github.com/goccy/echo-tools/notifier"
+	"github.com/google/pprof/profile"
+)
+
+const (
+	defaultSummaryTopN = 20
+)
+
+type summaryConfig struct {
+	topN              int
+	botName           string
+	discordWebhookURL string
+	githubToken       string
+}
+
+// SummaryOption renders a Markdown summary of the CPU profile as summary_<time>.md on Stop,
+// and shares it by gist and Discord if githubToken and webhookURL are specified, in the same way as the access log reports.
+// topN is the number of functions and routes in the summary ( 20 if it is zero ).
+func SummaryOption(topN int, botName, webhookURL, githubToken string) ProfilerOption {
+	return func(p *Profiler) {
+		if topN <= 0 {
+			topN = defaultSummaryTopN
+		}
+		p.summary = &summaryConfig{
+			topN:              topN,
+			botName:           botName,
+			discordWebhookURL: webhookURL,
+			githubToken:       githubToken,
+		}
+	}
+}
+
+func summaryFileName(currentTime string) string {
+	return fmt.Sprintf("summary_%s.md", currentTime)
+}
+
+// writeSummary writes the summary of prof and shares it.
+func (p *Profiler) writeSummary(ctx context.Context, prof *profile.Profile) (Artifact, error) {
+	path := filepath.Join(p.baseDir, summaryFileName(p.currentTime))
+	f, err := os.Create(path)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	defer f.Close()
+	title := fmt.Sprintf("cpu profile %s", p.currentTime)
+	if run, ok := RunFromContext(ctx); ok {
+		title = reportTitle("cpu profile", run.Title())
+	}
+	if err := renderSummary(f, title, prof, p.manifest, p.summary.topN); err != nil {
+		return Artifact{}, fmt.Errorf("failed to write summary: %w", err)
+	}
+	artifact := Artifact{Name: "summary", Path: path}
+	if p.summary.githubToken == "" || p.summary.discordWebhookURL == "" {
+		return artifact, nil
+	}
+	log.Print("[profiler] send summary to gist")
+	url, err := shareReport(ctx, title, title, path, p.summary.botName, p.summary.discordWebhookURL, p.summary.githubToken)
+	if err != nil {
+		return artifact, err
+	}
+	artifact.URL = url
+	return artifact, nil
+}
+
+// shareReport uploads the file at path to gist with description and posts title and the URL to Discord.
+func shareReport(ctx context.Context, description, title, path, botName, webhookURL, githubToken string) (string, error) {
+	client, err := gist.NewClient(ctx, githubToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to create gist client: %w", err)
+	}
+	url, err := client.UploadFile(ctx, description, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", path, err)
+	}
+	if botName == "" {
+		botName = "bot"
+	}
+	discordClient := notifier.NewDiscordClient(webhookURL)
+	if err := discordClient.Post(&notifier.DiscordMessage{
+		Username: botName,
+		Content:  fmt.Sprintf("%s: %s", title, url),
+	}); err != nil {
+		return url, fmt.Errorf("failed to post message to discord: %w", err)
+	}
+	return url, nil
+}
+
+// renderSummary writes the Markdown summary of prof: the sample count, the duration, the top flat and cumulative functions
+// and the top routes if prof is labeled by middleware.PprofLabels.
+func renderSummary(w io.Writer, title string, prof *profile.Profile, manifest *Manifest, topN int) error {
+	stats, total := topFunctions(prof)
+	var samples int64
+	for _, sample := range prof.Sample {
+		if len(sample.Value) != 0 {
+			samples += sample.Value[0]
+		}
+	}
+	format := summaryValueFormatter(prof)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- duration: %s\n", time.Duration(prof.DurationNanos).Round(time.Millisecond))
+	fmt.Fprintf(&b, "- samples: %d\n", samples)
+	fmt.Fprintf(&b, "- total: %s\n", format(total))
+	if manifest != nil {
+		for _, ctx := range manifestContext(manifest) {
+			fmt.Fprintf(&b, "- %s\n", ctx)
+		}
+	}
+
+	b.WriteString("\n## Top flat\n\n")
+	writeSummaryFunctions(&b, stats, total, topN, format)
+
+	cumStats := append([]*functionStat{}, stats...)
+	sort.SliceStable(cumStats, func(i, j int) bool {
+		return cumStats[i].Cum > cumStats[j].Cum
+	})
+	b.WriteString("\n## Top cumulative\n\n")
+	writeSummaryFunctions(&b, cumStats, total, topN, format)
+
+	if routes, routeTotal := routeStats(prof); routes != nil {
+		b.WriteString("\n## Top routes\n\n")
+		b.WriteString("| route | cpu | cpu% |\n")
+		b.WriteString("|---|---:|---:|\n")
+		for idx, stat := range routes {
+			if idx >= topN {
+				break
+			}
+			fmt.Fprintf(&b, "| %s | %s | %.1f%% |\n", escapeMarkdownCell(stat.name()), stat.CPU, percent(int64(stat.CPU), int64(routeTotal)))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeSummaryFunctions(b *strings.Builder, stats []*functionStat, total int64, topN int, format func(int64) string) {
+	b.WriteString("| flat | flat% | cum | cum% | function |\n")
+	b.WriteString("|---:|---:|---:|---:|---|\n")
+	for idx, stat := range stats {
+		if idx >= topN {
+			break
+		}
+		fmt.Fprintf(b, "| %s | %.1f%% | %s | %.1f%% | %s |\n",
+			format(stat.Flat), percent(stat.Flat, total),
+			format(stat.Cum), percent(stat.Cum, total),
+			escapeMarkdownCell(stat.Name),
+		)
+	}
+}
+
+// summaryValueFormatter formats the last sample value of prof as a duration if its unit is nanoseconds.
+func summaryValueFormatter(prof *profile.Profile) func(int64) string {
+	if len(prof.SampleType) != 0 && prof.SampleType[len(prof.SampleType)-1].Unit == "nanoseconds" {
+		return func(v int64) string {
+			return time.Duration(v).Round(time.Millisecond).String()
+		}
+	}
+	return func(v int64) string {
+		return fmt.Sprint(v)
+	}
+}
+
+func escapeMarkdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
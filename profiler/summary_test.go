@@ -0,0 +1,44 @@
+package profiler_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestProfilerSummary(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.SummaryOption(5, "", "", ""))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "summary_*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected one summary but got %v", matches)
+	}
+	b, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, section := range []string{"- samples:", "## Top flat", "## Top cumulative"} {
+		if !strings.Contains(string(b), section) {
+			t.Fatalf("%s is not found in summary:\n%s", section, b)
+		}
+	}
+	runs, err := p.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || len(runs[0].Manifest.Artifacts) != 1 || runs[0].Manifest.Artifacts[0].Path != matches[0] {
+		t.Fatalf("summary is not recorded: %+v", runs)
+	}
+}
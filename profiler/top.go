@@ -0,0 +1,63 @@
+package profiler
+
+import (
+	"sort"
+
+	"github.com/google/pprof/profile"
+)
+
+type functionStat struct {
+	Name string
+	Flat int64
+	Cum  int64
+}
+
+// topFunctions aggregates the last sample value ( e.g. cpu nanoseconds ) of prof by function and returns them sorted by flat value.
+func topFunctions(prof *profile.Profile) ([]*functionStat, int64) {
+	if len(prof.SampleType) == 0 {
+		return nil, 0
+	}
+	valueIdx := len(prof.SampleType) - 1
+	statMap := map[string]*functionStat{}
+	stat := func(name string) *functionStat {
+		s, exists := statMap[name]
+		if !exists {
+			s = &functionStat{Name: name}
+			statMap[name] = s
+		}
+		return s
+	}
+	var total int64
+	for _, sample := range prof.Sample {
+		value := sample.Value[valueIdx]
+		total += value
+		seen := map[string]struct{}{}
+		for locIdx, loc := range sample.Location {
+			for lineIdx, line := range loc.Line {
+				if line.Function == nil {
+					continue
+				}
+				name := line.Function.Name
+				if locIdx == 0 && lineIdx == 0 {
+					stat(name).Flat += value
+				}
+				if _, exists := seen[name]; exists {
+					continue
+				}
+				seen[name] = struct{}{}
+				stat(name).Cum += value
+			}
+		}
+	}
+	stats := make([]*functionStat, 0, len(statMap))
+	for _, s := range statMap {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Flat == stats[j].Flat {
+			return stats[i].Name < stats[j].Name
+		}
+		return stats[i].Flat > stats[j].Flat
+	})
+	return stats, total
+}
@@ -0,0 +1,278 @@
+package profiler
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+const (
+	traceViewerStartTimeout = 30 * time.Second
+	traceViewerEndpoint     = "/trace-viewer"
+)
+
+// TraceOption records an execution trace by runtime/trace for every run.
+// Tracing begins delay after Start and lasts for duration. If duration is zero, it lasts until Stop.
+func TraceOption(delay, duration time.Duration) ProfilerOption {
+	return func(p *Profiler) {
+		p.traceEnabled = true
+		p.traceDelay = delay
+		p.traceDuration = duration
+	}
+}
+
+// TraceViewerPortOption specifies the port of `go tool trace` started from the web UI.
+// It listens on 127.0.0.1 only and the web UI proxies it. By default, a free port is chosen.
+func TraceViewerPortOption(port uint16) ProfilerOption {
+	return func(p *Profiler) {
+		p.traceViewer.port = port
+	}
+}
+
+func traceFileName(currentTime string) string {
+	return fmt.Sprintf("trace_%s.out", currentTime)
+}
+
+type tracer struct {
+	mu      sync.Mutex
+	file    *os.File
+	running bool
+	timers  []*time.Timer
+}
+
+func (p *Profiler) startTrace() error {
+	if !p.traceEnabled {
+		return nil
+	}
+	path := filepath.Join(p.baseDir, traceFileName(p.currentTime))
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	t := &tracer{file: f}
+	p.tracer = t
+	log.Printf("start trace: report to %s", path)
+	if p.traceDelay == 0 {
+		if err := t.start(); err != nil {
+			return err
+		}
+	} else {
+		t.timers = append(t.timers, time.AfterFunc(p.traceDelay, func() {
+			if err := t.start(); err != nil {
+				log.Printf("failed to start trace: %+v", err)
+			}
+		}))
+	}
+	if p.traceDuration > 0 {
+		t.timers = append(t.timers, time.AfterFunc(p.traceDelay+p.traceDuration, t.stop))
+	}
+	return nil
+}
+
+func (p *Profiler) stopTrace() {
+	if p.tracer == nil {
+		return
+	}
+	p.tracer.close()
+	p.tracer = nil
+}
+
+func (t *tracer) start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	if err := trace.Start(t.file); err != nil {
+		return fmt.Errorf("failed to start trace: %w", err)
+	}
+	t.running = true
+	return nil
+}
+
+func (t *tracer) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopLocked()
+}
+
+func (t *tracer) stopLocked() {
+	if t.running {
+		trace.Stop()
+		t.running = false
+	}
+}
+
+// close stops the trace and closes the file in one critical section, so a timer firing meanwhile can't start the trace again.
+func (t *tracer) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, timer := range t.timers {
+		timer.Stop()
+	}
+	t.stopLocked()
+	t.file.Close()
+	t.file = nil
+}
+
+// traceViewer runs `go tool trace` for one trace file at a time on 127.0.0.1 and proxies it,
+// so the viewer is protected by the credential of the web UI as well.
+type traceViewer struct {
+	mu    sync.Mutex
+	port  uint16
+	path  string
+	cmd   *exec.Cmd
+	proxy *httputil.ReverseProxy
+}
+
+func (v *traceViewer) open(path string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.cmd != nil && v.path == path {
+		return nil
+	}
+	v.stopLocked()
+	port := int(v.port)
+	if port == 0 {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return fmt.Errorf("failed to find free port: %w", err)
+		}
+		port = l.Addr().(*net.TCPAddr).Port
+		l.Close()
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	cmd := exec.Command("go", "tool", "trace", "-http="+addr, path)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to exec go tool trace: %w", err)
+	}
+	deadline := time.Now().Add(traceViewerStartTimeout)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return fmt.Errorf("go tool trace did not start listening on %s", addr)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	v.cmd = cmd
+	v.path = path
+	v.proxy = httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: addr})
+	return nil
+}
+
+// stopIfViewing stops `go tool trace` if it views one of files, which are the files of a run to delete.
+func (v *traceViewer) stopIfViewing(files []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, file := range files {
+		if v.cmd != nil && v.path == file {
+			v.stopLocked()
+			return
+		}
+	}
+}
+
+func (v *traceViewer) stop() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.stopLocked()
+}
+
+func (v *traceViewer) stopLocked() {
+	if v.cmd == nil {
+		return
+	}
+	v.cmd.Process.Kill()
+	v.cmd.Wait()
+	v.cmd = nil
+	v.path = ""
+	v.proxy = nil
+}
+
+// ServeHTTP proxies the request to `go tool trace` . The pages of it link to absolute paths such as /trace and /static/ ,
+// so the index page passes unknown paths here as well.
+func (v *traceViewer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	proxy := v.proxy
+	v.mu.Unlock()
+	if proxy == nil {
+		http.NotFound(w, r)
+		return
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+var traceTmpl = newPageTemplate("trace", `<!DOCTYPE html>
+<html>
+<head><title>trace {{ .Name }}</title></head>
+<body>
+{{ template "nav" .Links }}
+<h2>{{ .Name }}</h2>
+<p>size: {{ .Size }} bytes</p>
+<ul>
+  <li><a href="./view">open trace viewer</a> ( starts <code>go tool trace</code> on this host )</li>
+  <li><a href="./download">download</a> and run <code>go tool trace {{ .Name }}</code></li>
+</ul>
+</body>
+</html>`)
+
+type traceHandler struct {
+	path   string
+	links  []navLink
+	viewer *traceViewer
+}
+
+func (p *Profiler) mountTrace(prefix, path string, nav []navLink) {
+	h := &traceHandler{path: path, links: nav, viewer: p.traceViewer}
+	p.mux.HandleFunc(prefix+"/trace/", h.index)
+	p.mux.HandleFunc(prefix+"/trace/download", h.download)
+	p.mux.HandleFunc(prefix+"/trace/view", h.view)
+}
+
+func (h *traceHandler) index(w http.ResponseWriter, r *http.Request) {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if err := traceTmpl.Execute(w, struct {
+		Name  string
+		Size  int64
+		Links []navLink
+	}{
+		Name:  filepath.Base(h.path),
+		Size:  info.Size(),
+		Links: h.links,
+	}); err != nil {
+		log.Printf("failed to render trace page: %+v", err)
+	}
+}
+
+func (h *traceHandler) download(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(h.path)))
+	http.ServeFile(w, r, h.path)
+}
+
+func (h *traceHandler) view(w http.ResponseWriter, r *http.Request) {
+	if err := h.viewer.open(h.path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, traceViewerEndpoint+"/", http.StatusFound)
+}
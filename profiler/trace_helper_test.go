@@ -0,0 +1,24 @@
+package profiler
+
+import (
+	"net/http/httputil"
+	"net/url"
+	"os"
+)
+
+// SetTraceViewerBackend makes the trace viewer proxy to u instead of `go tool trace` .
+func SetTraceViewerBackend(p *Profiler, u *url.URL) {
+	p.traceViewer.mu.Lock()
+	defer p.traceViewer.mu.Unlock()
+	p.traceViewer.proxy = httputil.NewSingleHostReverseProxy(u)
+}
+
+// TraceViewerProcess returns the process of `go tool trace` started by the trace viewer.
+func TraceViewerProcess(p *Profiler) *os.Process {
+	p.traceViewer.mu.Lock()
+	defer p.traceViewer.mu.Unlock()
+	if p.traceViewer.cmd == nil {
+		return nil
+	}
+	return p.traceViewer.cmd.Process
+}
@@ -0,0 +1,345 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	trendEndpoint = "/trends"
+	trendTopN     = 10
+	// a value is highlighted when it grows by this ratio from the previous run.
+	trendRegressionRatio = 1.2
+	// the share of a function is highlighted only when it grows by this percentage point at least.
+	trendMinShareDelta = 1.0
+	sparklineWidth     = 120
+	sparklineHeight    = 24
+)
+
+// routeLatency is a row of the access log report of alp.
+type routeLatency struct {
+	Method string
+	URI    string
+	Count  int
+	Avg    float64
+	Sum    float64
+}
+
+func (l *routeLatency) name() string {
+	return fmt.Sprintf("%s %s", l.Method, l.URI)
+}
+
+// parseALPTable parses the table or markdown output of alp. It returns nil if the columns are not found.
+func parseALPTable(r io.Reader) ([]*routeLatency, error) {
+	var (
+		columns map[string]int
+		ret     []*routeLatency
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		fields := strings.Split(strings.Trim(line, "|"), "|")
+		for idx := range fields {
+			fields[idx] = strings.TrimSpace(fields[idx])
+		}
+		if columns == nil {
+			header := map[string]int{}
+			for idx, field := range fields {
+				header[strings.ToUpper(field)] = idx
+			}
+			if hasColumns(header, "COUNT", "METHOD", "URI", "AVG", "SUM") {
+				columns = header
+			}
+			continue
+		}
+		count, err := strconv.Atoi(fields[columns["COUNT"]])
+		if err != nil {
+			// separator of markdown
+			continue
+		}
+		avg, _ := strconv.ParseFloat(fields[columns["AVG"]], 64)
+		sum, _ := strconv.ParseFloat(fields[columns["SUM"]], 64)
+		ret = append(ret, &routeLatency{
+			Method: fields[columns["METHOD"]],
+			URI:    fields[columns["URI"]],
+			Count:  count,
+			Avg:    avg,
+			Sum:    sum,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alp output: %w", err)
+	}
+	return ret, nil
+}
+
+func hasColumns(header map[string]int, names ...string) bool {
+	for _, name := range names {
+		if _, exists := header[name]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// runRouteLatencies returns the access log stats of the run if the report of alp exists on this host.
+func runRouteLatencies(m *Manifest) []*routeLatency {
+	if m == nil {
+		return nil
+	}
+	for _, artifact := range m.Artifacts {
+		if artifact.Name != "alp" || artifact.Path == "" {
+			continue
+		}
+		f, err := os.Open(artifact.Path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		latencies, err := parseALPTable(f)
+		if err != nil {
+			log.Printf("failed to parse %s: %+v", artifact.Path, err)
+			return nil
+		}
+		return latencies
+	}
+	return nil
+}
+
+var trendTmpl = newPageTemplate("trend", `<!DOCTYPE html>
+<html>
+<head>
+<title>trends</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; white-space: nowrap; }
+td.name { text-align: left; }
+td.regression { background: #fdd; }
+td.improvement { background: #dfd; }
+</style>
+</head>
+<body>
+<h2>trends</h2>
+<p>cells are highlighted when the value changes by {{ .Ratio }}x or more from the previous run ( red: regression, green: improvement ).</p>
+{{- range .Tables }}
+<h3>{{ .Title }}</h3>
+{{ if .Rows }}
+<table>
+<tr><th></th><th></th>{{ range $.Runs }}<th><a href="/{{ .Idx }}/">#{{ .Idx }}</a><br>{{ .Time }}</th>{{ end }}</tr>
+{{- range .Rows }}
+<tr>
+  <td class="name">{{ .Name }}</td>
+  <td><svg width="{{ $.SparklineWidth }}" height="{{ $.SparklineHeight }}" xmlns="http://www.w3.org/2000/svg"><polyline points="{{ .Sparkline }}" fill="none" stroke="#2a66d9"/></svg></td>
+  {{- range .Cells }}<td class="{{ .Class }}">{{ .Value }}</td>{{ end }}
+</tr>
+{{- end }}
+</table>
+{{ else }}
+<p>{{ .Empty }}</p>
+{{ end }}
+{{- end }}
+</body>
+</html>`)
+
+type trendRun struct {
+	Idx  int
+	Time string
+}
+
+type trendCell struct {
+	Value string
+	Class string
+}
+
+type trendRow struct {
+	Name      string
+	Sparkline string
+	Cells     []trendCell
+}
+
+type trendTable struct {
+	Title string
+	Empty string
+	Rows  []*trendRow
+}
+
+// trendHandler shows the share of the hot functions and the latency of the routes of every loaded run.
+type trendHandler struct {
+	profiler *Profiler
+}
+
+type functionShare struct {
+	flat float64
+	cum  float64
+}
+
+func (h *trendHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	runs := h.profiler.loadedRuns()
+	// runs imported or found by the watcher later may be older than the others, so the deltas are computed in the order of time.
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].time.Before(runs[j].time)
+	})
+	trendRuns := make([]trendRun, 0, len(runs))
+	shares := make([]map[string]functionShare, 0, len(runs))
+	latencies := make([]map[string]*routeLatency, 0, len(runs))
+	h.profiler.runsMu.RLock()
+	for _, run := range runs {
+		trendRuns = append(trendRuns, trendRun{Idx: run.idx, Time: run.time.Format("01-02 15:04")})
+		stats, total := topFunctions(run.cpu)
+		share := map[string]functionShare{}
+		for _, stat := range stats {
+			share[stat.Name] = functionShare{flat: percent(stat.Flat, total), cum: percent(stat.Cum, total)}
+		}
+		shares = append(shares, share)
+		latency := map[string]*routeLatency{}
+		for _, l := range runRouteLatencies(run.manifest) {
+			latency[l.name()] = l
+		}
+		latencies = append(latencies, latency)
+	}
+	h.profiler.runsMu.RUnlock()
+
+	functionTable := &trendTable{Title: "hot functions ( flat% / cum% )", Empty: "no run."}
+	for _, name := range topTrendKeys(len(shares), func(idx int) map[string]float64 {
+		ret := map[string]float64{}
+		for name, share := range shares[idx] {
+			ret[name] = share.flat
+		}
+		return ret
+	}) {
+		row := &trendRow{Name: name}
+		values := make([]float64, 0, len(shares))
+		for idx, share := range shares {
+			cur := share[name]
+			cell := trendCell{Value: "-"}
+			if cur.flat != 0 || cur.cum != 0 {
+				cell.Value = fmt.Sprintf("%.1f%% / %.1f%%", cur.flat, cur.cum)
+			}
+			if idx > 0 {
+				cell.Class = trendClass(shares[idx-1][name].flat, cur.flat, trendMinShareDelta)
+			}
+			row.Cells = append(row.Cells, cell)
+			values = append(values, cur.flat)
+		}
+		row.Sparkline = sparkline(values)
+		functionTable.Rows = append(functionTable.Rows, row)
+	}
+
+	routeTable := &trendTable{Title: "route latency ( avg sec / count )", Empty: "no access log stats ( the report of alp on this host ) found."}
+	for _, name := range topTrendKeys(len(latencies), func(idx int) map[string]float64 {
+		ret := map[string]float64{}
+		for name, l := range latencies[idx] {
+			ret[name] = l.Sum
+		}
+		return ret
+	}) {
+		row := &trendRow{Name: name}
+		values := make([]float64, 0, len(latencies))
+		for idx, latency := range latencies {
+			cell := trendCell{Value: "-"}
+			var avg float64
+			if cur, exists := latency[name]; exists {
+				avg = cur.Avg
+				cell.Value = fmt.Sprintf("%.3f / %d", cur.Avg, cur.Count)
+				if idx > 0 {
+					if prev, exists := latencies[idx-1][name]; exists {
+						cell.Class = trendClass(prev.Avg, cur.Avg, 0)
+					}
+				}
+			}
+			row.Cells = append(row.Cells, cell)
+			values = append(values, avg)
+		}
+		row.Sparkline = sparkline(values)
+		routeTable.Rows = append(routeTable.Rows, row)
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := trendTmpl.Execute(w, struct {
+		Ratio           float64
+		Runs            []trendRun
+		Tables          []*trendTable
+		SparklineWidth  int
+		SparklineHeight int
+	}{
+		Ratio:           trendRegressionRatio,
+		Runs:            trendRuns,
+		Tables:          []*trendTable{functionTable, routeTable},
+		SparklineWidth:  sparklineWidth,
+		SparklineHeight: sparklineHeight,
+	}); err != nil {
+		log.Printf("failed to render trend page: %+v", err)
+	}
+}
+
+// topTrendKeys returns the keys with the largest values over every run.
+func topTrendKeys(runs int, values func(int) map[string]float64) []string {
+	maxValues := map[string]float64{}
+	for idx := 0; idx < runs; idx++ {
+		for key, v := range values(idx) {
+			if v > maxValues[key] {
+				maxValues[key] = v
+			}
+		}
+	}
+	keys := make([]string, 0, len(maxValues))
+	for key, v := range maxValues {
+		if v > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if maxValues[keys[i]] == maxValues[keys[j]] {
+			return keys[i] < keys[j]
+		}
+		return maxValues[keys[i]] > maxValues[keys[j]]
+	})
+	if len(keys) > trendTopN {
+		keys = keys[:trendTopN]
+	}
+	return keys
+}
+
+// trendClass compares the value with the previous run. Larger values are regressions.
+func trendClass(prev, cur, minDelta float64) string {
+	switch {
+	case cur-prev >= minDelta && cur > prev*trendRegressionRatio:
+		return "regression"
+	case prev-cur >= minDelta && prev > cur*trendRegressionRatio:
+		return "improvement"
+	}
+	return ""
+}
+
+func sparkline(values []float64) string {
+	var maxValue float64
+	for _, v := range values {
+		if v > maxValue {
+			maxValue = v
+		}
+	}
+	points := make([]string, 0, len(values))
+	for idx, v := range values {
+		x := float64(sparklineWidth) / 2
+		if len(values) > 1 {
+			x = float64(sparklineWidth-2)*float64(idx)/float64(len(values)-1) + 1
+		}
+		y := float64(sparklineHeight) - 1
+		if maxValue > 0 {
+			y -= (float64(sparklineHeight) - 2) * v / maxValue
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return strings.Join(points, " ")
+}
@@ -0,0 +1,3 @@
+package profiler
+
+var ParseALPTable = parseALPTable
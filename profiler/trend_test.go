@@ -0,0 +1,115 @@
+package profiler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestParseALPTable(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "table",
+			input: `+-------+-----+-----+-----+-----+-----+--------+-------------+-------+-------+-------+-------+
+| COUNT | 1XX | 2XX | 3XX | 4XX | 5XX | METHOD |     URI     |  MIN  |  MAX  |  SUM  |  AVG  |
++-------+-----+-----+-----+-----+-----+--------+-------------+-------+-------+-------+-------+
+|    10 |   0 |  10 |   0 |   0 |   0 | GET    | /users/.+   | 0.010 | 0.100 | 0.500 | 0.050 |
+|     2 |   0 |   2 |   0 |   0 |   0 | POST   | /initialize | 1.000 | 1.000 | 2.000 | 1.000 |
++-------+-----+-----+-----+-----+-----+--------+-------------+-------+-------+-------+-------+
+`,
+		},
+		{
+			name: "markdown",
+			input: `| COUNT | 1XX | 2XX | 3XX | 4XX | 5XX | METHOD | URI | MIN | MAX | SUM | AVG |
+|---|---|---|---|---|---|---|---|---|---|---|---|
+| 10 | 0 | 10 | 0 | 0 | 0 | GET | /users/.+ | 0.010 | 0.100 | 0.500 | 0.050 |
+| 2 | 0 | 2 | 0 | 0 | 0 | POST | /initialize | 1.000 | 1.000 | 2.000 | 1.000 |
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			latencies, err := profilertools.ParseALPTable(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(latencies) != 2 {
+				t.Fatalf("expected two routes but got %d", len(latencies))
+			}
+			l := latencies[0]
+			if l.Method != "GET" || l.URI != "/users/.+" || l.Count != 10 || l.Avg != 0.05 || l.Sum != 0.5 {
+				t.Fatalf("unexpected route: %+v", l)
+			}
+		})
+	}
+}
+
+// writeTrendRun writes a run whose manifest has the report of alp with the average latency avg.
+func writeTrendRun(t *testing.T, dir, id string, avg float64) {
+	t.Helper()
+	writeTestRuns(t, dir, id)
+	alpPath := filepath.Join(dir, "alp_"+id+".txt")
+	table := fmt.Sprintf("| COUNT | 1XX | 2XX | 3XX | 4XX | 5XX | METHOD | URI | MIN | MAX | SUM | AVG |\n"+
+		"|---|---|---|---|---|---|---|---|---|---|---|---|\n"+
+		"| 10 | 0 | 10 | 0 | 0 | 0 | GET | /users/:id | %.3f | %.3f | %.3f | %.3f |\n", avg, avg, avg*10, avg)
+	if err := os.WriteFile(alpPath, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	startTime, err := time.ParseInLocation("2006_01_02_15_04_05", id, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(&profilertools.Manifest{
+		StartTime: startTime,
+		Artifacts: []profilertools.Artifact{{Name: "alp", Path: alpPath}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest_"+id+".json"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrendOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeTrendRun(t, dir, "2020_01_01_00_02_00", 0.2)
+	writeTrendRun(t, dir, "2020_01_01_00_03_00", 0.1)
+	p := profilertools.NewProfiler(dir)
+	h, err := profilertools.ServeHandler(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the imported run is older than the loaded runs but mounted last.
+	src := t.TempDir()
+	writeTrendRun(t, src, "2020_01_01_00_01_00", 0.1)
+	var bundle bytes.Buffer
+	if err := profilertools.NewProfiler(src).ExportRun("2020_01_01_00_01_00", &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ImportRun(&bundle); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/trends", nil))
+	body := rec.Body.String()
+	first, second, third := strings.Index(body, `<a href="/2/">`), strings.Index(body, `<a href="/0/">`), strings.Index(body, `<a href="/1/">`)
+	if first < 0 || first > second || second > third {
+		t.Fatalf("runs are not ordered by time:\n%s", body)
+	}
+	// 0.1 -> 0.2 is a regression and 0.2 -> 0.1 is an improvement.
+	if !strings.Contains(body, `<td class="">0.100 / 10</td><td class="regression">`) || !strings.Contains(body, `<td class="improvement">`) {
+		t.Fatalf("unexpected deltas:\n%s", body)
+	}
+}
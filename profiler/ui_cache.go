@@ -0,0 +1,61 @@
+package profiler
+
+import (
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultUICacheSize = 8
+)
+
+// uiCache keeps the pprof web UIs built on demand such as the merged segments, up to size.
+// The UIs are served through it instead of being mounted on the mux, which can't remove them, so the least recently used one can be freed.
+type uiCache struct {
+	mu       sync.Mutex
+	size     int
+	keys     []string
+	handlers map[string]http.Handler
+}
+
+func newUICache(size int) *uiCache {
+	return &uiCache{size: size, handlers: map[string]http.Handler{}}
+}
+
+// get returns the UI of key. If it isn't cached, it is built by build and the least recently used one is evicted.
+func (c *uiCache) get(key string, build func() (http.Handler, error)) (http.Handler, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h, exists := c.handlers[key]; exists {
+		c.remove(key)
+		c.keys = append(c.keys, key)
+		return h, nil
+	}
+	h, err := build()
+	if err != nil {
+		return nil, err
+	}
+	c.handlers[key] = h
+	c.keys = append(c.keys, key)
+	if len(c.keys) > c.size {
+		oldest := c.keys[0]
+		c.remove(oldest)
+		delete(c.handlers, oldest)
+	}
+	return h, nil
+}
+
+func (c *uiCache) remove(key string) {
+	for idx, k := range c.keys {
+		if k == key {
+			c.keys = append(c.keys[:idx], c.keys[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (c *uiCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.handlers)
+}
@@ -0,0 +1,82 @@
+package profiler
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 10 * time.Second
+	// profiles without manifest ( e.g. created by older versions ) are mounted after they are not modified for this duration.
+	defaultWatchManifestTimeout = time.Minute
+)
+
+// WatchIntervalOption specifies the interval to look for profiles added to baseDir by other processes while serving the web UI.
+// If interval is zero, baseDir is not watched.
+func WatchIntervalOption(interval time.Duration) ProfilerOption {
+	return func(p *Profiler) {
+		p.watchInterval = interval
+	}
+}
+
+func (p *Profiler) watch(interval time.Duration) {
+	// a profile is mounted once its size has not changed for an interval and its manifest is written,
+	// because other processes may still be writing the files of the run.
+	pending := map[string]int64{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, path := range p.findNewProfiles() {
+			info, err := os.Stat(path)
+			if err != nil {
+				delete(pending, path)
+				continue
+			}
+			size, exists := pending[path]
+			if !exists || size != info.Size() || size == 0 {
+				pending[path] = info.Size()
+				continue
+			}
+			if !hasManifest(path) && time.Since(info.ModTime()) < p.watchManifestTimeout {
+				continue
+			}
+			delete(pending, path)
+			log.Printf("found new profile %s", path)
+			// the profile is tried again later if it fails, because it is marked as loaded only when it is mounted.
+			if err := p.addProfileResult(path, nil); err != nil {
+				log.Printf("failed to add profile result: %+v", err)
+			}
+		}
+	}
+}
+
+// findNewProfiles returns the profiles which are not loaded yet except the profile of the run this process is recording or stopping,
+// which Stop adds after the sub profilers finish.
+func (p *Profiler) findNewProfiles() []string {
+	paths := findCPUProfiles(p.baseDir)
+	sort.Strings(paths)
+	var recording string
+	p.stateMu.Lock()
+	if p.state != StateIdle && p.state != StateContinuous && p.pprofFile != nil {
+		recording = p.pprofFile.Name()
+	}
+	p.stateMu.Unlock()
+	p.mountMu.Lock()
+	defer p.mountMu.Unlock()
+	var ret []string
+	for _, path := range paths {
+		if _, exists := p.loaded[path]; exists || path == recording {
+			continue
+		}
+		ret = append(ret, path)
+	}
+	return ret
+}
+
+func hasManifest(pprofPath string) bool {
+	_, err := os.Stat(filepath.Join(filepath.Dir(pprofPath), manifestFileName(runID(pprofPath))))
+	return err == nil
+}
@@ -0,0 +1,103 @@
+package profiler_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	profilertools "github.com/goccy/echo-tools/profiler"
+)
+
+func TestProfilerWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRuns(t, dir, "2020_01_01_00_00_01")
+	p := profilertools.NewProfiler(dir, profilertools.WatchIntervalOption(50*time.Millisecond))
+	if _, err := profilertools.ServeHandler(p); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := profilertools.LoadedRunIDs(p); len(loaded) != 1 {
+		t.Fatalf("unexpected runs: %v", loaded)
+	}
+	// another process adds a run to baseDir.
+	writeTestRuns(t, dir, "2020_01_01_00_00_02")
+	if err := os.WriteFile(filepath.Join(dir, "manifest_2020_01_01_00_00_02.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		loaded := profilertools.LoadedRunIDs(p)
+		if len(loaded) == 2 && loaded[1] == "2020_01_01_00_00_02" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the new run is not loaded: %v", loaded)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// slowStopProfiler calls stop in Stop like a sub profiler running pt-query-digest.
+type slowStopProfiler struct {
+	stop func()
+}
+
+func (s *slowStopProfiler) Start() error { return nil }
+
+func (s *slowStopProfiler) Stop() error {
+	s.stop()
+	return nil
+}
+
+func TestProfilerWatchSkipsStoppingRun(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.WatchIntervalOption(20*time.Millisecond))
+	// the manifest of the run is written after the timeout.
+	profilertools.SetWatchManifestTimeout(p, 0)
+	var loadedWhileStopping []string
+	p.AddProfiler(&slowStopProfiler{stop: func() {
+		time.Sleep(300 * time.Millisecond)
+		loadedWhileStopping = profilertools.LoadedRunIDs(p)
+	}})
+	if _, err := profilertools.ServeHandler(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if len(loadedWhileStopping) != 0 {
+		t.Fatalf("the watcher loaded the run being stopped: %v", loadedWhileStopping)
+	}
+	if loaded := profilertools.LoadedRunIDs(p); len(loaded) != 1 {
+		t.Fatalf("unexpected runs: %v", loaded)
+	}
+}
+
+func TestProfilerWatchRetry(t *testing.T) {
+	dir := t.TempDir()
+	p := profilertools.NewProfiler(dir, profilertools.WatchIntervalOption(20*time.Millisecond))
+	profilertools.SetWatchManifestTimeout(p, 0)
+	if _, err := profilertools.ServeHandler(p); err != nil {
+		t.Fatal(err)
+	}
+	// the profile is broken while another process is writing it.
+	path := filepath.Join(dir, "pprof_2020_01_01_00_00_01.pprof")
+	if err := os.WriteFile(path, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if loaded := profilertools.LoadedRunIDs(p); len(loaded) != 0 {
+		t.Fatalf("unexpected runs: %v", loaded)
+	}
+	writeTestRuns(t, dir, "2020_01_01_00_00_01")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(profilertools.LoadedRunIDs(p)) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("the fixed profile is not loaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,85 @@
+package profiler
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type navLink struct {
+	Name string
+	Path string
+}
+
+const navTmplText = `{{ define "nav" }}<div style="padding:4px 8px;background:#eee;font-family:sans-serif;font-size:13px">
+{{- range . }}<a href="{{ .Path }}" style="margin-right:12px">{{ .Name }}</a>{{ end -}}
+</div>{{ end }}`
+
+var navTmpl = newPageTemplate("nav", "")
+
+// newPageTemplate parses a page that can render the navigation bar by {{ template "nav" .Links }}.
+func newPageTemplate(name, text string) *template.Template {
+	return template.Must(template.Must(template.New(name).Parse(navTmplText)).Parse(text))
+}
+
+// navHandler injects links to the other views of the same run into the HTML pages rendered by pprof.
+type navHandler struct {
+	handler http.Handler
+	links   []navLink
+}
+
+func (h *navHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(h.links) < 2 {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	bw := &bufferedResponseWriter{header: http.Header{}, status: http.StatusOK}
+	h.handler.ServeHTTP(bw, r)
+	body := bw.buf.Bytes()
+	if strings.HasPrefix(bw.header.Get("Content-Type"), "text/html") {
+		body = injectNav(body, h.links)
+	}
+	for k, v := range bw.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(bw.status)
+	w.Write(body)
+}
+
+func injectNav(body []byte, links []navLink) []byte {
+	idx := bytes.Index(body, []byte("<body>"))
+	if idx < 0 {
+		return body
+	}
+	idx += len("<body>")
+	var nav bytes.Buffer
+	if err := navTmpl.ExecuteTemplate(&nav, "nav", links); err != nil {
+		return body
+	}
+	ret := make([]byte, 0, len(body)+nav.Len())
+	ret = append(ret, body[:idx]...)
+	ret = append(ret, nav.Bytes()...)
+	ret = append(ret, body[idx:]...)
+	return ret
+}
+
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
@@ -0,0 +1,40 @@
+package profiler
+
+import (
+	"net/http"
+	"time"
+)
+
+// ServeHandler prepares the web UI like ListenAndServe and returns the handler instead of listening.
+func ServeHandler(p *Profiler) (http.Handler, error) {
+	if err := p.setupServer(); err != nil {
+		return nil, err
+	}
+	return p.auth.handler(p.mux), nil
+}
+
+// LoadedRunIDs returns the ids of the runs served by the web UI.
+func LoadedRunIDs(p *Profiler) []string {
+	p.runsMu.RLock()
+	defer p.runsMu.RUnlock()
+	ids := make([]string, 0, len(p.runs))
+	for _, r := range p.runs {
+		ids = append(ids, r.id)
+	}
+	return ids
+}
+
+// CachedSegmentUIs returns the number of the web UIs of the merged segments kept in the cache.
+func CachedSegmentUIs(p *Profiler) int {
+	return p.segmentUIs.len()
+}
+
+// CachedDiffUIs returns the number of the web UIs of diffs kept in the cache.
+func CachedDiffUIs(p *Profiler) int {
+	return p.diffUIs.len()
+}
+
+// SetWatchManifestTimeout changes how long the watcher waits for the manifest of a new profile.
+func SetWatchManifestTimeout(p *Profiler, timeout time.Duration) {
+	p.watchManifestTimeout = timeout
+}
@@ -0,0 +1,121 @@
+package slowlog
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/goccy/echo-tools/fingerprint"
+	"github.com/goccy/echo-tools/metric"
+)
+
+// Class is the statements which have the same fingerprint.
+type Class struct {
+	ID           string
+	Fingerprint  string
+	Schema       string
+	Example      *Entry
+	Count        int
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	QueryTime    *metric.Metric
+	LockTime     *metric.Metric
+	RowsSent     *metric.Metric
+	RowsExamined *metric.Metric
+	QuerySize    *metric.Metric
+}
+
+// Aggregator groups entries by the fingerprint of the statement like pt-query-digest.
+type Aggregator struct {
+	classes   map[string]*Class
+	total     *metric.Metric
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{classes: map[string]*Class{}, total: metric.New()}
+}
+
+func (a *Aggregator) Add(e *Entry) {
+	fp := fingerprint.Normalize(e.Query)
+	c, exists := a.classes[fp]
+	if !exists {
+		c = &Class{
+			ID:           fingerprint.Hash(fp),
+			Fingerprint:  fp,
+			Schema:       e.Schema,
+			QueryTime:    metric.New(),
+			LockTime:     metric.New(),
+			RowsSent:     metric.New(),
+			RowsExamined: metric.New(),
+			QuerySize:    metric.New(),
+		}
+		a.classes[fp] = c
+	}
+	c.Count++
+	if c.Example == nil || e.QueryTime > c.Example.QueryTime {
+		c.Example = e
+	}
+	if !e.Time.IsZero() {
+		if c.FirstSeen.IsZero() || e.Time.Before(c.FirstSeen) {
+			c.FirstSeen = e.Time
+		}
+		if e.Time.After(c.LastSeen) {
+			c.LastSeen = e.Time
+		}
+		if a.firstSeen.IsZero() || e.Time.Before(a.firstSeen) {
+			a.firstSeen = e.Time
+		}
+		if e.Time.After(a.lastSeen) {
+			a.lastSeen = e.Time
+		}
+	}
+	c.QueryTime.Add(e.QueryTime)
+	c.LockTime.Add(e.LockTime)
+	c.RowsSent.Add(float64(e.RowsSent))
+	c.RowsExamined.Add(float64(e.RowsExamined))
+	c.QuerySize.Add(float64(len(e.Query)))
+	a.total.Add(e.QueryTime)
+}
+
+// Digest is the result of Aggregator. Classes are sorted by the total query time.
+type Digest struct {
+	Classes   []*Class
+	Count     int
+	QueryTime float64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+func (a *Aggregator) Digest() *Digest {
+	classes := make([]*Class, 0, len(a.classes))
+	for _, c := range a.classes {
+		classes = append(classes, c)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if classes[i].QueryTime.Sum == classes[j].QueryTime.Sum {
+			return classes[i].Fingerprint < classes[j].Fingerprint
+		}
+		return classes[i].QueryTime.Sum > classes[j].QueryTime.Sum
+	})
+	return &Digest{
+		Classes:   classes,
+		Count:     a.total.Count(),
+		QueryTime: a.total.Sum,
+		FirstSeen: a.firstSeen,
+		LastSeen:  a.lastSeen,
+	}
+}
+
+// ParseDigest aggregates every entry of the slow query log.
+func ParseDigest(r io.Reader) (*Digest, error) {
+	a := NewAggregator()
+	if err := Parse(r, func(e *Entry) error {
+		a.Add(e)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return a.Digest(), nil
+}
@@ -0,0 +1,192 @@
+package slowlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a statement recorded in the MySQL slow query log.
+type Entry struct {
+	Time         time.Time
+	User         string
+	Host         string
+	Schema       string
+	QueryTime    float64
+	LockTime     float64
+	RowsSent     int64
+	RowsExamined int64
+	Query        string
+}
+
+var (
+	headerFieldRe = regexp.MustCompile(`([A-Za-z_]+): +(\S+)`)
+	userHostRe    = regexp.MustCompile(`^User@Host: ([^\[\s]*)(?:\[[^\]]*\])? @ (\S*) ?\[([^\]]*)\]`)
+	useRe         = regexp.MustCompile(`(?i)^use +` + "`?" + `([^` + "`" + `;\s]+)` + "`?" + ` *;$`)
+	timestampRe   = regexp.MustCompile(`(?i)^SET timestamp=(\d+);$`)
+)
+
+// the time formats of "# Time:" of MySQL 5.7 or later and of MySQL 5.6 or earlier.
+var timeFormats = []string{time.RFC3339Nano, "060102 15:04:05"}
+
+// Parser reads the entries of a slow query log one by one.
+type Parser struct {
+	scanner *bufio.Scanner
+	entry   *Entry
+	query   []string
+	// header is true while reading the comment lines of an entry.
+	header bool
+	err    error
+}
+
+func NewParser(r io.Reader) *Parser {
+	scanner := bufio.NewScanner(r)
+	// a statement such as a bulk insert can be a long line.
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &Parser{scanner: scanner}
+}
+
+// Next returns the next entry. It returns io.EOF after the last entry.
+func (p *Parser) Next() (*Entry, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	for p.scanner.Scan() {
+		line := strings.TrimRight(p.scanner.Text(), "\r")
+		if entry := p.parseLine(line); entry != nil {
+			return entry, nil
+		}
+	}
+	if err := p.scanner.Err(); err != nil {
+		p.err = fmt.Errorf("failed to read slow query log: %w", err)
+		return nil, p.err
+	}
+	p.err = io.EOF
+	if entry := p.flush(); entry != nil {
+		return entry, nil
+	}
+	return nil, io.EOF
+}
+
+// parseLine returns the previous entry if line starts a new one.
+func (p *Parser) parseLine(line string) *Entry {
+	switch {
+	case isServerHeader(line):
+		// mysqld writes these lines when it starts or flushes the logs.
+		return p.flush()
+	case strings.HasPrefix(line, "# administrator command: "):
+		if p.entry != nil {
+			p.query = append(p.query, strings.TrimPrefix(line, "# "))
+			p.header = false
+		}
+		return nil
+	case strings.HasPrefix(line, "# "):
+		var prev *Entry
+		if !p.header {
+			prev = p.flush()
+			p.entry = &Entry{}
+			p.header = true
+		}
+		p.parseHeader(strings.TrimPrefix(line, "# "))
+		return prev
+	}
+	if p.entry == nil {
+		return nil
+	}
+	if p.header || len(p.query) == 0 {
+		if matched := useRe.FindStringSubmatch(line); matched != nil {
+			p.entry.Schema = matched[1]
+			p.header = false
+			return nil
+		}
+		if matched := timestampRe.FindStringSubmatch(line); matched != nil {
+			if p.entry.Time.IsZero() {
+				sec, _ := strconv.ParseInt(matched[1], 10, 64)
+				p.entry.Time = time.Unix(sec, 0).UTC()
+			}
+			p.header = false
+			return nil
+		}
+	}
+	p.header = false
+	p.query = append(p.query, line)
+	return nil
+}
+
+func isServerHeader(line string) bool {
+	return strings.Contains(line, ", Version: ") && strings.Contains(line, "started with:") ||
+		strings.HasPrefix(line, "Tcp port: ") ||
+		strings.HasPrefix(line, "Time ") && strings.Contains(line, "Id Command")
+}
+
+func (p *Parser) parseHeader(line string) {
+	e := p.entry
+	if strings.HasPrefix(line, "Time: ") {
+		value := strings.Join(strings.Fields(strings.TrimPrefix(line, "Time: ")), " ")
+		for _, format := range timeFormats {
+			if t, err := time.Parse(format, value); err == nil {
+				e.Time = t
+				break
+			}
+		}
+		return
+	}
+	if matched := userHostRe.FindStringSubmatch(line); matched != nil {
+		e.User = matched[1]
+		e.Host = matched[2]
+		if e.Host == "" {
+			e.Host = matched[3]
+		}
+		return
+	}
+	for _, field := range headerFieldRe.FindAllStringSubmatch(line, -1) {
+		value := field[2]
+		switch field[1] {
+		case "Query_time":
+			e.QueryTime, _ = strconv.ParseFloat(value, 64)
+		case "Lock_time":
+			e.LockTime, _ = strconv.ParseFloat(value, 64)
+		case "Rows_sent":
+			e.RowsSent, _ = strconv.ParseInt(value, 10, 64)
+		case "Rows_examined":
+			e.RowsExamined, _ = strconv.ParseInt(value, 10, 64)
+		case "Schema":
+			e.Schema = value
+		}
+	}
+}
+
+// flush returns the entry being read if it has a statement.
+func (p *Parser) flush() *Entry {
+	entry := p.entry
+	query := strings.TrimSpace(strings.Join(p.query, "\n"))
+	p.entry = nil
+	p.query = nil
+	p.header = false
+	if entry == nil || query == "" {
+		return nil
+	}
+	entry.Query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	return entry
+}
+
+// Parse reads every entry of the slow query log and calls fn with it.
+func Parse(r io.Reader, fn func(*Entry) error) error {
+	p := NewParser(r)
+	for {
+		entry, err := p.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
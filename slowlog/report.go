@@ -0,0 +1,195 @@
+package slowlog
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/goccy/echo-tools/metric"
+)
+
+const reportTimeFormat = "2006-01-02T15:04:05"
+
+var itemTableRe = regexp.MustCompile("(?i)\\b(?:from|join|into|update|table)\\s+`?([A-Za-z0-9_$.]+)")
+
+// Item is the short name of the class such as "SELECT users posts" in the profile of the report.
+func (c *Class) Item() string {
+	fields := strings.Fields(c.Fingerprint)
+	if len(fields) == 0 {
+		return ""
+	}
+	item := []string{strings.ToUpper(fields[0])}
+	seen := map[string]struct{}{}
+	for _, matched := range itemTableRe.FindAllStringSubmatch(c.Fingerprint, -1) {
+		table := matched[1]
+		if _, exists := seen[table]; exists {
+			continue
+		}
+		seen[table] = struct{}{}
+		item = append(item, table)
+	}
+	return strings.Join(item, " ")
+}
+
+// WriteReport writes the report in the format of pt-query-digest: the overall stats, the profile of the classes
+// and the details of the top topN classes ( every class if topN is zero ).
+func (d *Digest) WriteReport(w io.Writer, topN int) error {
+	var b strings.Builder
+	overall := d.overallAttributes()
+	fmt.Fprintf(&b, "# Overall: %s total, %d unique", formatCount(float64(d.Count)), len(d.Classes))
+	if !d.FirstSeen.IsZero() {
+		fmt.Fprintf(&b, ", %s to %s", d.FirstSeen.Format(reportTimeFormat), d.LastSeen.Format(reportTimeFormat))
+	}
+	b.WriteString("\n")
+	writeAttributeHeader(&b, false)
+	for _, attr := range overall {
+		writeAttribute(&b, attr.name, attr.metric, attr.format, -1)
+	}
+
+	b.WriteString("\n# Profile\n")
+	fmt.Fprintf(&b, "# %4s %-18s %14s %6s %7s %5s %s\n", "Rank", "Query ID", "Response time", "Calls", "R/Call", "V/M", "Item")
+	fmt.Fprintf(&b, "# %4s %-18s %14s %6s %7s %5s %s\n", "====", strings.Repeat("=", 18), strings.Repeat("=", 14), "======", "=======", "=====", strings.Repeat("=", 14))
+	for idx, c := range d.Classes {
+		fmt.Fprintf(&b, "# %4d %-18s %8.4f %4.1f%% %6d %7.4f %5.2f %s\n",
+			idx+1, c.ID, c.QueryTime.Sum, percentOf(c.QueryTime.Sum, d.QueryTime), c.Count, c.QueryTime.Avg(), varianceToMean(c.QueryTime), c.Item())
+	}
+
+	for idx, c := range d.Classes {
+		if topN > 0 && idx >= topN {
+			break
+		}
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "# Query %d: ID %s\n", idx+1, c.ID)
+		if !c.FirstSeen.IsZero() {
+			fmt.Fprintf(&b, "# Time range: %s to %s\n", c.FirstSeen.Format(reportTimeFormat), c.LastSeen.Format(reportTimeFormat))
+		}
+		writeAttributeHeader(&b, true)
+		fmt.Fprintf(&b, "# %-13s %3.0f %7d\n", "Count", percentOf(float64(c.Count), float64(d.Count)), c.Count)
+		for i, attr := range c.attributes() {
+			writeAttribute(&b, attr.name, attr.metric, attr.format, percentOf(attr.metric.Sum, overall[i].metric.Sum))
+		}
+		if c.Schema != "" {
+			fmt.Fprintf(&b, "# Databases    %s\n", c.Schema)
+		}
+		if c.Example != nil && c.Example.User != "" {
+			fmt.Fprintf(&b, "# Users        %s\n", c.Example.User)
+		}
+		if c.Example != nil {
+			fmt.Fprintf(&b, "%s\\G\n", c.Example.Query)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type attribute struct {
+	name   string
+	metric *metric.Metric
+	format func(float64) string
+}
+
+func (c *Class) attributes() []attribute {
+	return []attribute{
+		{name: "Exec time", metric: c.QueryTime, format: formatSeconds},
+		{name: "Lock time", metric: c.LockTime, format: formatSeconds},
+		{name: "Rows sent", metric: c.RowsSent, format: formatCount},
+		{name: "Rows examine", metric: c.RowsExamined, format: formatCount},
+		{name: "Query size", metric: c.QuerySize, format: formatBytes},
+	}
+}
+
+// overallAttributes merges the attributes of every class.
+func (d *Digest) overallAttributes() []attribute {
+	var attrs []attribute
+	for _, c := range d.Classes {
+		for i, attr := range c.attributes() {
+			if len(attrs) <= i {
+				attrs = append(attrs, attribute{name: attr.name, metric: metric.New(), format: attr.format})
+			}
+			attrs[i].metric.Merge(attr.metric)
+		}
+	}
+	if attrs == nil {
+		attrs = (&Class{QueryTime: metric.New(), LockTime: metric.New(), RowsSent: metric.New(), RowsExamined: metric.New(), QuerySize: metric.New()}).attributes()
+	}
+	return attrs
+}
+
+func writeAttributeHeader(b *strings.Builder, pct bool) {
+	if pct {
+		fmt.Fprintf(b, "# %-13s %3s %7s %7s %7s %7s %7s %7s %7s\n", "Attribute", "pct", "total", "min", "max", "avg", "95%", "stddev", "median")
+		fmt.Fprintf(b, "# %-13s %3s %7s %7s %7s %7s %7s %7s %7s\n", "=============", "===", "=======", "=======", "=======", "=======", "=======", "=======", "=======")
+		return
+	}
+	fmt.Fprintf(b, "# %-13s %7s %7s %7s %7s %7s %7s %7s\n", "Attribute", "total", "min", "max", "avg", "95%", "stddev", "median")
+	fmt.Fprintf(b, "# %-13s %7s %7s %7s %7s %7s %7s %7s\n", "=============", "=======", "=======", "=======", "=======", "=======", "=======", "=======")
+}
+
+// writeAttribute doesn't write the pct column if pct is negative.
+func writeAttribute(b *strings.Builder, name string, m *metric.Metric, format func(float64) string, pct float64) {
+	if m.Count() == 0 {
+		return
+	}
+	fmt.Fprintf(b, "# %-13s ", name)
+	if pct >= 0 {
+		fmt.Fprintf(b, "%3.0f ", pct)
+	}
+	fmt.Fprintf(b, "%7s %7s %7s %7s %7s %7s %7s\n",
+		format(m.Sum), format(m.Min), format(m.Max), format(m.Avg()), format(m.Percentile(95)), format(m.Stddev()), format(m.Percentile(50)))
+}
+
+func percentOf(v, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return v / total * 100
+}
+
+// varianceToMean is V/M of pt-query-digest which shows how the response time varies.
+func varianceToMean(m *metric.Metric) float64 {
+	avg := m.Avg()
+	if avg == 0 {
+		return 0
+	}
+	stddev := m.Stddev()
+	return stddev * stddev / avg
+}
+
+func formatSeconds(v float64) string {
+	d := time.Duration(v * float64(time.Second))
+	switch {
+	case d >= time.Second:
+		return fmt.Sprintf("%.0fs", d.Seconds())
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%.0fms", float64(d)/float64(time.Millisecond))
+	case d > 0:
+		return fmt.Sprintf("%.0fus", float64(d)/float64(time.Microsecond))
+	}
+	return "0"
+}
+
+func formatCount(v float64) string {
+	switch {
+	case v >= 1e9:
+		return fmt.Sprintf("%.2fG", v/1e9)
+	case v >= 1e6:
+		return fmt.Sprintf("%.2fM", v/1e6)
+	case v >= 1e3:
+		return fmt.Sprintf("%.2fk", v/1e3)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+func formatBytes(v float64) string {
+	switch {
+	case v >= 1<<30:
+		return fmt.Sprintf("%.2fG", v/(1<<30))
+	case v >= 1<<20:
+		return fmt.Sprintf("%.2fM", v/(1<<20))
+	case v >= 1<<10:
+		return fmt.Sprintf("%.2fk", v/(1<<10))
+	}
+	return fmt.Sprintf("%.0f", v)
+}
@@ -0,0 +1,108 @@
+package slowlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSlowQueryLog = `/usr/sbin/mysqld, Version: 8.0.32 (MySQL Community Server - GPL). started with:
+Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock
+Time                 Id Command    Argument
+# Time: 2024-01-02T03:04:05.123456Z
+# User@Host: isucon[isucon] @ localhost []  Id:     8
+# Query_time: 0.500000  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000
+use isuports;
+SET timestamp=1704164645;
+SELECT * FROM users WHERE id = 10;
+# Time: 2024-01-02T03:04:06.000000Z
+# User@Host: isucon[isucon] @ localhost []  Id:     8
+# Query_time: 1.500000  Lock_time: 0.000200 Rows_sent: 1  Rows_examined: 2000
+SET timestamp=1704164646;
+SELECT *
+  FROM users
+  WHERE id = 20;
+# Time: 240102  3:04:07
+# User@Host: isucon[isucon] @  [192.168.0.1]
+# Query_time: 0.100000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 3
+SET timestamp=1704164647;
+INSERT INTO posts (user_id, body) VALUES (1, 'hello'), (2, 'it''s');
+# User@Host: isucon[isucon] @ localhost []
+# Query_time: 0.010000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0
+SET timestamp=1704164648;
+# administrator command: Quit;
+`
+
+func TestParse(t *testing.T) {
+	var entries []*Entry
+	if err := Parse(strings.NewReader(testSlowQueryLog), func(e *Entry) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("unexpected entries: %d", len(entries))
+	}
+	first := entries[0]
+	if first.User != "isucon" || first.Host != "localhost" || first.Schema != "isuports" {
+		t.Fatalf("unexpected entry: %+v", first)
+	}
+	if first.QueryTime != 0.5 || first.LockTime != 0.0001 || first.RowsSent != 1 || first.RowsExamined != 1000 {
+		t.Fatalf("unexpected entry: %+v", first)
+	}
+	if !first.Time.Equal(time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)) {
+		t.Fatalf("unexpected time: %s", first.Time)
+	}
+	if first.Query != "SELECT * FROM users WHERE id = 10" {
+		t.Fatalf("unexpected query: %q", first.Query)
+	}
+	if entries[1].Query != "SELECT *\n  FROM users\n  WHERE id = 20" {
+		t.Fatalf("unexpected query: %q", entries[1].Query)
+	}
+	if entries[2].Host != "192.168.0.1" || !entries[2].Time.Equal(time.Date(2024, 1, 2, 3, 4, 7, 0, time.UTC)) {
+		t.Fatalf("unexpected entry: %+v", entries[2])
+	}
+	if entries[3].Query != "administrator command: Quit" || entries[3].Time.Unix() != 1704164648 {
+		t.Fatalf("unexpected entry: %+v", entries[3])
+	}
+}
+
+func TestDigest(t *testing.T) {
+	digest, err := ParseDigest(strings.NewReader(testSlowQueryLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest.Count != 4 || len(digest.Classes) != 3 {
+		t.Fatalf("unexpected digest: %d entries %d classes", digest.Count, len(digest.Classes))
+	}
+	top := digest.Classes[0]
+	if top.Fingerprint != "select * from users where id = ?" || top.Count != 2 || top.QueryTime.Sum != 2 {
+		t.Fatalf("unexpected class: %+v", top)
+	}
+	if top.Example.QueryTime != 1.5 || top.QueryTime.Max != 1.5 || top.QueryTime.Min != 0.5 || top.RowsExamined.Sum != 3000 {
+		t.Fatalf("unexpected class: %+v", top)
+	}
+	if top.Item() != "SELECT users" || digest.Classes[1].Item() != "INSERT posts" {
+		t.Fatalf("unexpected items: %s %s", top.Item(), digest.Classes[1].Item())
+	}
+
+	var b bytes.Buffer
+	if err := digest.WriteReport(&b, 0); err != nil {
+		t.Fatal(err)
+	}
+	report := b.String()
+	for _, expected := range []string{
+		"# Overall: 4 total, 3 unique, 2024-01-02T03:04:05 to 2024-01-02T03:04:08",
+		"# Exec time",
+		"# Profile",
+		"# Query 1: ID " + top.ID,
+		"# Databases    isuports",
+		"SELECT *\n  FROM users\n  WHERE id = 20\\G",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Fatalf("report doesn't contain %q:\n%s", expected, report)
+		}
+	}
+}